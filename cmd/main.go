@@ -3,11 +3,13 @@ package main
 import (
 	"TelegramBot/internal/config"
 	"TelegramBot/internal/httpserver"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
 	"TelegramBot/internal/storage"
 	"TelegramBot/internal/telegram"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,35 +17,47 @@ import (
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 func main() {
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel, cfg.LogMessageText)
 	dsn := os.Getenv("DATABASE_URL")
 	u, _ := url.Parse(dsn)
-	log.Printf("DB host=%s port=%s db=%s", u.Hostname(), u.Port(), strings.TrimPrefix(u.Path, "/"))
+	slog.Info("database", "host", u.Hostname(), "port", u.Port(), "db", strings.TrimPrefix(u.Path, "/"))
 
 	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("telegram auth failed", logging.Err(err))
 	}
-	fmt.Printf("Authorized on account %s\n", bot.Self.UserName)
+	slog.Info("authorized", "bot", bot.Self.UserName)
+	telegram.InstrumentClient(bot)
 	cmds := []tgbotapi.BotCommand{
 		{Command: "start", Description: "Помощь и кнопки"},
 		{Command: "timezone", Description: "Часовой пояс"},
 		{Command: "report", Description: "Ежедневный отчёт (HH:MM | off)"},
+		{Command: "quiet", Description: "Тихие часы (23:00-08:00 | off)"},
 		{Command: "list", Description: "Список: today | week | all"},
 		{Command: "timetable", Description: "Расписание"},
+		{Command: "calendar", Description: "Ссылка на календарь (ICS)"},
+		{Command: "token", Description: "Токен REST API"},
+		{Command: "history", Description: "Прошедшие напоминания"},
+		{Command: "find", Description: "Поиск по напоминаниям"},
+		{Command: "export", Description: "Выгрузить данные чата (JSON)"},
+		{Command: "import", Description: "Восстановить из выгрузки"},
+		{Command: "forget", Description: "Удалить все данные чата"},
 	}
 	if _, err := bot.Request(tgbotapi.NewSetMyCommands(cmds...)); err != nil {
-		log.Printf("setMyCommands: %v", err)
+		slog.Warn("setMyCommands failed", logging.Err(err))
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	store, err := storage.New(ctx, cfg.DBUrl)
 	if err != nil {
-		log.Fatalf("store failed: %v", err)
+		logging.Fatal("store init failed", logging.Err(err))
 
 	}
 	defer store.Close()
@@ -52,7 +66,7 @@ func main() {
 		_, err := store.Now(c)
 		return err
 	}); err != nil {
-		log.Fatalf("db not ready: %v", err)
+		logging.Fatal("db not ready", logging.Err(err))
 	}
 
 	params := tgbotapi.Params{}
@@ -62,34 +76,83 @@ func main() {
 
 	resp, err := bot.MakeRequest("setWebhook", params)
 	if err != nil || !resp.Ok {
-		log.Fatalf("setWebhook failed: err=%v ok=%v desc=%s", err, resp.Ok, resp.Description)
+		logging.Fatal("setWebhook failed", logging.Err(err), "ok", resp.Ok, "description", resp.Description)
 	}
 
 	updates := make(chan tgbotapi.Update, 100)
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "telegrambot_updates_queue_depth",
+		Help: "Updates waiting in the channel for a worker.",
+	}, func() float64 { return float64(len(updates)) })
 
 	workers := 2
 	for i := 0; i < workers; i++ {
 		go func(id int) {
 			for update := range updates {
-				HandleUpdate(bot, update, store)
+				HandleUpdate(bot, update, store, cfg)
 			}
 		}(i)
 	}
 
-	notifier := &telegram.Notifier{Bot: bot, Store: store}
+	notifier := &telegram.Notifier{
+		Bot:         bot,
+		Store:       store,
+		MaxAttempts: cfg.JobMaxAttempts,
+		CatchUp:     telegram.CatchUpPolicy(cfg.CatchUpPolicy),
+		MaxLate:     cfg.CatchUpMaxLate,
+		Retention:   cfg.HistoryRetention,
+	}
 	go notifier.Run(context.Background())
 
-	handler := httpserver.New(cfg.WebhookSecret, updates)
-	log.Printf("HTTP server listening on :%s", cfg.Port)
+	health := &httpserver.Health{
+		Store:      store,
+		Bot:        bot,
+		Notifier:   notifier,
+		WebhookURL: cfg.SelfURL + "/webhook",
+	}
+	// /metrics и подробный /healthz слушают отдельный, внутренний адрес:
+	// на публичном порту их мог бы читать кто угодно.
+	if cfg.MetricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			mux.HandleFunc("/healthz", health.Details)
+			slog.Info("metrics server listening", "addr", cfg.MetricsAddr)
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				slog.Error("metrics server failed", logging.Err(err))
+			}
+		}()
+	}
+
+	handler := httpserver.New(cfg.WebhookSecret, updates, store, health)
+	slog.Info("http server listening", "port", cfg.Port)
 	if err := http.ListenAndServe(":"+cfg.Port, handler); err != nil {
-		log.Fatalf("http server error: %v", err)
+		logging.Fatal("http server failed", logging.Err(err))
 	}
 
 }
 
-func HandleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, store *storage.Storage) {
+func HandleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, store *storage.Storage, cfg config.Config) {
+	kind := telegram.UpdateKind(update)
+	defer metrics.UpdatesProcessed.WithLabelValues(kind).Inc()
+
+	logger := slog.With("update_id", update.UpdateID, "update_type", kind)
+	if chat := update.FromChat(); chat != nil {
+		logger = logger.With("chat_id", chat.ID)
+	}
+	if from := update.SentFrom(); from != nil {
+		logger = logger.With("user_id", from.ID)
+	}
+	ctx := logging.WithContext(context.Background(), logger)
+
 	if update.Message != nil {
-		telegram.HandleMessage(bot, store, update.Message)
+		logger.Debug("update received", logging.Text(update.Message.Text))
+		telegram.HandleMessage(ctx, bot, store, cfg, update.Message)
+		return
+	}
+	if update.CallbackQuery != nil {
+		logger.Debug("update received", "data", update.CallbackQuery.Data)
+		telegram.HandleCallback(ctx, bot, store, update.CallbackQuery)
 		return
 	}
 }
@@ -110,7 +173,7 @@ func waitForDB(ctx context.Context, ping func(context.Context) error) error {
 		if err == nil {
 			return nil
 		}
-		log.Printf("db ping failed (%d/%d): %v — retry in %v", i+1, len(backoff), err, d)
+		slog.Warn("db ping failed", "attempt", i+1, "of", len(backoff), "retry_in", d, logging.Err(err))
 		time.Sleep(d)
 	}
 	return fmt.Errorf("database not reachable after retries")
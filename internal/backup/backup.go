@@ -0,0 +1,329 @@
+// Package backup — формат выгрузки данных чата для /export и /import:
+// версионированный JSON, который можно загрузить в другой чат или другую
+// установку бота, и набор CSV-таблиц для просмотра в электронных таблицах.
+package backup
+
+import (
+	"TelegramBot/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Format — метка в файле, по которой /import узнаёт выгрузку.
+	Format = "telegrambot-chat-export"
+	// Version — текущая версия схемы. Новые поля добавляются без смены
+	// версии; версия растёт, когда меняется смысл существующих.
+	Version = 1
+)
+
+type File struct {
+	Format     string      `json:"format"`
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at"`
+	ChatID     int64       `json:"chat_id"`
+	Settings   *Settings   `json:"settings,omitempty"`
+	Reminders  []Reminder  `json:"reminders"`
+	Schedule   []Entry     `json:"schedule"`
+	Exceptions []Exception `json:"exceptions"`
+}
+
+// Settings — настройки чата; время — "HH:MM".
+type Settings struct {
+	TimeZone        string  `json:"time_zone"`
+	Locale          string  `json:"locale,omitempty"`
+	DailyReportTime *string `json:"daily_report_time"`
+	QuietFrom       *string `json:"quiet_from"`
+	QuietTo         *string `json:"quiet_to"`
+	QuietMode       string  `json:"quiet_mode"`
+	Holidays        string  `json:"holidays"`
+}
+
+// Reminder — напоминание вместе со своими job'ами. ID — исходный, при
+// импорте выдаётся новый.
+type Reminder struct {
+	ID             int64      `json:"id"`
+	Message        string     `json:"message"`
+	EventTime      *time.Time `json:"event_time"`
+	Rule           *string    `json:"rule"`
+	NextReport     *time.Time `json:"next_report"`
+	LeadMinutes    []int      `json:"lead_minutes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Persistent     bool       `json:"persistent"`
+	NagInterval    int        `json:"nag_interval,omitempty"`
+	NagMax         int        `json:"nag_max,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	UserID         *int64     `json:"user_id,omitempty"`
+	Author         string     `json:"author,omitempty"`
+	Broadcast      bool       `json:"broadcast,omitempty"`
+	AssigneeID     *int64     `json:"assignee_id,omitempty"`
+	Assignee       string     `json:"assignee,omitempty"`
+	FiredAt        *time.Time `json:"fired_at,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	SnoozedCount   int        `json:"snoozed_count,omitempty"`
+	Jobs           []Job      `json:"jobs"`
+}
+
+// Job — отправка напоминания: ожидающая (все отметки пустые) или прошлая.
+type Job struct {
+	ReportTime    time.Time  `json:"report_time"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	SkippedAt     *time.Time `json:"skipped_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	Attempts      int        `json:"attempts,omitempty"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NagSeq        int        `json:"nag_seq,omitempty"`
+}
+
+// Entry — запись недельного расписания, поля как в API /schedule.
+type Entry struct {
+	Weekday int     `json:"weekday"`
+	Start   string  `json:"start"`
+	End     *string `json:"end"`
+	Title   string  `json:"title"`
+	// parity: 0 — каждую неделю, 1 — нечётные (числитель), 2 — чётные
+	Parity    int     `json:"parity,omitempty"`
+	ValidFrom *string `json:"valid_from,omitempty"`
+	ValidTo   *string `json:"valid_to,omitempty"`
+	Location  string  `json:"location,omitempty"`
+	Notes     string  `json:"notes,omitempty"`
+	UserID    *int64  `json:"user_id,omitempty"`
+	Owner     string  `json:"owner,omitempty"`
+}
+
+// Exception — разовое изменение расписания: kind "cancel" или "add".
+type Exception struct {
+	Date   string  `json:"date"`
+	Kind   string  `json:"kind"`
+	Start  *string `json:"start"`
+	End    *string `json:"end"`
+	Title  string  `json:"title"`
+	UserID *int64  `json:"user_id,omitempty"`
+	Owner  string  `json:"owner,omitempty"`
+}
+
+// FromDump собирает файл выгрузки из данных чата.
+func FromDump(chatID int64, d storage.ChatDump, now time.Time) File {
+	f := File{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: now.UTC(),
+		ChatID:     chatID,
+		Reminders:  []Reminder{},
+		Schedule:   []Entry{},
+		Exceptions: []Exception{},
+	}
+	if cs := d.Settings; cs != nil {
+		f.Settings = &Settings{
+			TimeZone:        cs.TimeZone,
+			Locale:          cs.LocaleLanguage,
+			DailyReportTime: hhmm(cs.DailyReportTime),
+			QuietFrom:       hhmm(cs.QuietFrom),
+			QuietTo:         hhmm(cs.QuietTo),
+			QuietMode:       cs.QuietMode,
+			Holidays:        cs.Holidays,
+		}
+	}
+	jobs := map[int64][]Job{}
+	for _, j := range d.Jobs {
+		jobs[j.ReminderID] = append(jobs[j.ReminderID], Job{
+			ReportTime: j.ReportTime.UTC(), SentAt: j.SentAt, SkippedAt: j.SkippedAt, FailedAt: j.FailedAt,
+			NextAttemptAt: j.NextAttemptAt, Attempts: j.Attempts, LastError: j.LastError, NagSeq: j.NagSeq,
+		})
+	}
+	for _, r := range d.Reminders {
+		rj := jobs[r.ID]
+		if rj == nil {
+			rj = []Job{}
+		}
+		f.Reminders = append(f.Reminders, Reminder{
+			ID: r.ID, Message: r.Message, EventTime: r.EventTime, Rule: r.ReminderRule, NextReport: r.NextReport,
+			LeadMinutes: r.ReminderOffsets, CreatedAt: r.CreatedAt, Persistent: r.Persistent,
+			NagInterval: r.NagInterval, NagMax: r.NagMax, AcknowledgedAt: r.AcknowledgedAt,
+			UserID: r.UserID, Author: r.AuthorName, Broadcast: r.Broadcast,
+			AssigneeID: r.AssigneeID, Assignee: r.AssigneeName,
+			FiredAt: r.FiredAt, ArchivedAt: r.ArchivedAt, SnoozedCount: r.SnoozedCount, Jobs: rj,
+		})
+	}
+	for _, e := range d.Schedule {
+		f.Schedule = append(f.Schedule, Entry{
+			Weekday: e.Weekday, Start: e.StartTime.Format("15:04"), End: hhmm(e.EndTime), Title: e.Title,
+			Parity: e.Parity, ValidFrom: date(e.ValidFrom), ValidTo: date(e.ValidTo),
+			Location: e.Location, Notes: e.Notes, UserID: e.UserID, Owner: e.OwnerName,
+		})
+	}
+	for _, x := range d.Exceptions {
+		f.Exceptions = append(f.Exceptions, Exception{
+			Date: x.Date.Format(time.DateOnly), Kind: x.Kind, Start: hhmm(x.StartTime), End: hhmm(x.EndTime),
+			Title: x.Title, UserID: x.UserID, Owner: x.OwnerName,
+		})
+	}
+	return f
+}
+
+// Decode читает файл выгрузки и проверяет метку формата и версию.
+func Decode(data []byte) (File, error) {
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("не JSON: %w", err)
+	}
+	if f.Format != Format {
+		return f, errors.New("это не выгрузка бота")
+	}
+	if f.Version < 1 || f.Version > Version {
+		return f, fmt.Errorf("версия выгрузки %d не поддерживается (поддерживается до %d)", f.Version, Version)
+	}
+	return f, nil
+}
+
+// Dump проверяет файл и переводит его в данные для storage.Restore.
+func (f File) Dump() (storage.ChatDump, error) {
+	d := storage.ChatDump{ChatID: f.ChatID}
+	if s := f.Settings; s != nil {
+		cs := storage.ChatSettings{TimeZone: s.TimeZone, LocaleLanguage: s.Locale, QuietMode: s.QuietMode, Holidays: s.Holidays}
+		var err error
+		if cs.DailyReportTime, err = parseClock(s.DailyReportTime); err != nil {
+			return d, fmt.Errorf("settings.daily_report_time: %w", err)
+		}
+		if cs.QuietFrom, err = parseClock(s.QuietFrom); err != nil {
+			return d, fmt.Errorf("settings.quiet_from: %w", err)
+		}
+		if cs.QuietTo, err = parseClock(s.QuietTo); err != nil {
+			return d, fmt.Errorf("settings.quiet_to: %w", err)
+		}
+		if cs.TimeZone == "" {
+			cs.TimeZone = "UTC"
+		}
+		if cs.LocaleLanguage == "" {
+			cs.LocaleLanguage = "ru"
+		}
+		if cs.QuietMode == "" {
+			cs.QuietMode = storage.QuietDelay
+		}
+		switch cs.Holidays {
+		case "":
+			cs.Holidays = storage.HolidaysOff
+		case storage.HolidaysOff, storage.HolidaysTimetable, storage.HolidaysAll:
+		default:
+			return d, fmt.Errorf("settings.holidays: неизвестный режим %q", cs.Holidays)
+		}
+		d.Settings = &cs
+	}
+
+	for i, r := range f.Reminders {
+		if r.Message == "" {
+			return d, fmt.Errorf("reminders[%d]: пустой текст", i)
+		}
+		if r.EventTime == nil && (r.Rule == nil || r.NextReport == nil) {
+			return d, fmt.Errorf("reminders[%d]: нет ни event_time, ни rule с next_report", i)
+		}
+		lead := r.LeadMinutes
+		if len(lead) == 0 {
+			lead = []int{0}
+		}
+		d.Reminders = append(d.Reminders, storage.Reminder{
+			ID: r.ID, Message: r.Message, EventTime: r.EventTime, ReminderTime: lead[0], ReminderOffsets: lead,
+			ReminderRule: r.Rule, NextReport: r.NextReport, CreatedAt: r.CreatedAt, Persistent: r.Persistent,
+			NagInterval: r.NagInterval, NagMax: r.NagMax, AcknowledgedAt: r.AcknowledgedAt,
+			UserID: r.UserID, AuthorName: r.Author, Broadcast: r.Broadcast,
+			AssigneeID: r.AssigneeID, AssigneeName: r.Assignee,
+			FiredAt: r.FiredAt, ArchivedAt: r.ArchivedAt, SnoozedCount: r.SnoozedCount,
+		})
+		for _, j := range r.Jobs {
+			d.Jobs = append(d.Jobs, storage.JobRecord{
+				ReminderID: r.ID, ReportTime: j.ReportTime, SentAt: j.SentAt, SkippedAt: j.SkippedAt,
+				FailedAt: j.FailedAt, NextAttemptAt: j.NextAttemptAt, Attempts: j.Attempts,
+				LastError: j.LastError, NagSeq: j.NagSeq,
+			})
+		}
+	}
+
+	for i, e := range f.Schedule {
+		if e.Weekday < 1 || e.Weekday > 7 || e.Title == "" {
+			return d, fmt.Errorf("schedule[%d]: нужны weekday 1–7 и title", i)
+		}
+		if e.Parity < storage.ParityAny || e.Parity > storage.ParityEven {
+			return d, fmt.Errorf("schedule[%d]: parity должна быть 0, 1 или 2", i)
+		}
+		start, err := parseClock(&e.Start)
+		if err != nil || start == nil {
+			return d, fmt.Errorf("schedule[%d].start: нужно время HH:MM", i)
+		}
+		w := storage.WeeklyEntry{Weekday: e.Weekday, StartTime: *start, Title: e.Title, Parity: e.Parity,
+			Location: e.Location, Notes: e.Notes, UserID: e.UserID, OwnerName: e.Owner}
+		if w.EndTime, err = parseClock(e.End); err != nil {
+			return d, fmt.Errorf("schedule[%d].end: %w", i, err)
+		}
+		if w.ValidFrom, err = parseDate(e.ValidFrom); err != nil {
+			return d, fmt.Errorf("schedule[%d].valid_from: %w", i, err)
+		}
+		if w.ValidTo, err = parseDate(e.ValidTo); err != nil {
+			return d, fmt.Errorf("schedule[%d].valid_to: %w", i, err)
+		}
+		d.Schedule = append(d.Schedule, w)
+	}
+
+	for i, x := range f.Exceptions {
+		if x.Kind != storage.ExceptionCancel && x.Kind != storage.ExceptionAdd {
+			return d, fmt.Errorf("exceptions[%d].kind: ожидается cancel или add", i)
+		}
+		day, err := parseDate(&x.Date)
+		if err != nil || day == nil {
+			return d, fmt.Errorf("exceptions[%d].date: нужна дата YYYY-MM-DD", i)
+		}
+		se := storage.ScheduleException{Date: *day, Kind: x.Kind, Title: x.Title, UserID: x.UserID, OwnerName: x.Owner}
+		if se.StartTime, err = parseClock(x.Start); err != nil {
+			return d, fmt.Errorf("exceptions[%d].start: %w", i, err)
+		}
+		if se.EndTime, err = parseClock(x.End); err != nil {
+			return d, fmt.Errorf("exceptions[%d].end: %w", i, err)
+		}
+		if se.Kind == storage.ExceptionAdd && se.StartTime == nil {
+			return d, fmt.Errorf("exceptions[%d]: у разового занятия нужно start", i)
+		}
+		d.Exceptions = append(d.Exceptions, se)
+	}
+	return d, nil
+}
+
+func hhmm(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("15:04")
+	return &s
+}
+
+func date(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.DateOnly)
+	return &s
+}
+
+func parseClock(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, *s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("не понял время %q", *s)
+}
+
+func parseDate(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, *s)
+	if err != nil {
+		return nil, fmt.Errorf("не понял дату %q", *s)
+	}
+	return &t, nil
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"time"
+)
+
+// weekdayNames — дни в schedule.csv; таблицу можно загрузить обратно
+// через /timetable import.
+var weekdayNames = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+var parityNames = []string{"", "odd", "even"}
+
+// CSV упаковывает выгрузку в zip с таблицами settings.csv, reminders.csv,
+// jobs.csv, schedule.csv и exceptions.csv. Файлы начинаются с BOM, чтобы
+// Excel правильно открыл кириллицу.
+func CSV(f File) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, header []string, rows [][]string) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: f.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	var settings [][]string
+	if s := f.Settings; s != nil {
+		settings = append(settings, []string{s.TimeZone, str(s.DailyReportTime), str(s.QuietFrom), str(s.QuietTo), s.QuietMode, s.Holidays})
+	}
+	if err := write("settings.csv", []string{"time_zone", "daily_report_time", "quiet_from", "quiet_to", "quiet_mode", "holidays"}, settings); err != nil {
+		return nil, err
+	}
+
+	var reminders, jobs [][]string
+	for _, r := range f.Reminders {
+		reminders = append(reminders, []string{
+			itoa(r.ID), r.Message, ts(r.EventTime), str(r.Rule), ts(r.NextReport), ints(r.LeadMinutes),
+			strconv.FormatBool(r.Persistent), ts(r.AcknowledgedAt), r.Author, r.Assignee,
+			strconv.FormatBool(r.Broadcast), ts(&r.CreatedAt),
+		})
+		for _, j := range r.Jobs {
+			jobs = append(jobs, []string{
+				itoa(r.ID), ts(&j.ReportTime), jobState(j), ts(j.SentAt), ts(j.SkippedAt), ts(j.FailedAt),
+				strconv.Itoa(j.Attempts), str(j.LastError), strconv.Itoa(j.NagSeq),
+			})
+		}
+	}
+	if err := write("reminders.csv", []string{"id", "message", "event_time", "rule", "next_report", "lead_minutes",
+		"persistent", "acknowledged_at", "author", "assignee", "broadcast", "created_at"}, reminders); err != nil {
+		return nil, err
+	}
+	if err := write("jobs.csv", []string{"reminder_id", "report_time", "state", "sent_at", "skipped_at", "failed_at",
+		"attempts", "last_error", "nag_seq"}, jobs); err != nil {
+		return nil, err
+	}
+
+	var schedule [][]string
+	for _, e := range f.Schedule {
+		parity := ""
+		if e.Parity >= 0 && e.Parity < len(parityNames) {
+			parity = parityNames[e.Parity]
+		}
+		schedule = append(schedule, []string{
+			weekdayNames[(e.Weekday+6)%7], e.Start, str(e.End), e.Title, e.Location, parity,
+			str(e.ValidFrom), str(e.ValidTo), e.Notes, e.Owner,
+		})
+	}
+	if err := write("schedule.csv", []string{"day", "start", "end", "title", "location", "parity",
+		"valid_from", "valid_to", "notes", "owner"}, schedule); err != nil {
+		return nil, err
+	}
+
+	var exceptions [][]string
+	for _, x := range f.Exceptions {
+		exceptions = append(exceptions, []string{x.Date, x.Kind, str(x.Start), str(x.End), x.Title, x.Owner})
+	}
+	if err := write("exceptions.csv", []string{"date", "kind", "start", "end", "title", "owner"}, exceptions); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func jobState(j Job) string {
+	switch {
+	case j.SentAt != nil:
+		return "sent"
+	case j.SkippedAt != nil:
+		return "skipped"
+	case j.FailedAt != nil:
+		return "failed"
+	}
+	return "pending"
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func ts(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
+
+func ints(v []int) string {
+	var b []byte
+	for i, n := range v {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendInt(b, int64(n), 10)
+	}
+	return string(b)
+}
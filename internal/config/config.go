@@ -1,44 +1,133 @@
 package config
 
 import (
-	"log"
+	"TelegramBot/internal/logging"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	BotToken      string
-	SelfURL       string
-	DBUrl         string
-	TimeZone      string
-	WebhookSecret string
-	Port          string
+	BotToken       string
+	SelfURL        string
+	DBUrl          string
+	TimeZone       string
+	WebhookSecret  string
+	Port           string
+	MetricsAddr    string
+	AdminChatIDs   []int64
+	JobMaxAttempts int
+	CatchUpPolicy  string
+	CatchUpMaxLate time.Duration
+	// HistoryRetention — сколько хранить историю напоминаний; 0 — всегда.
+	HistoryRetention time.Duration
+	LogFormat        string
+	LogLevel         string
+	LogMessageText   bool
 }
 
 func Load() Config {
 	cfg := Config{
-		BotToken:      os.Getenv("TELEGRAM_BOT_TOKEN"),
-		SelfURL:       os.Getenv("SELF_URL"),
-		DBUrl:         os.Getenv("DATABASE_URL"),
-		TimeZone:      os.Getenv("TIMEZONE"),
-		WebhookSecret: os.Getenv("TG_WEBHOOK_SECRET"),
-		Port:          os.Getenv("PORT"),
+		BotToken:       os.Getenv("TELEGRAM_BOT_TOKEN"),
+		SelfURL:        os.Getenv("SELF_URL"),
+		DBUrl:          os.Getenv("DATABASE_URL"),
+		TimeZone:       os.Getenv("TIMEZONE"),
+		WebhookSecret:  os.Getenv("TG_WEBHOOK_SECRET"),
+		Port:           os.Getenv("PORT"),
+		MetricsAddr:    "127.0.0.1:9090",
+		JobMaxAttempts: 5,
+		CatchUpPolicy:  "all",
+		CatchUpMaxLate: 15 * time.Minute,
+		LogFormat:      "text",
+		LogLevel:       "info",
 	}
 
 	if cfg.BotToken == "" {
-		log.Fatal("BotToken is empty")
+		logging.Fatal("BotToken is empty")
 	}
 	if cfg.DBUrl == "" {
-		log.Fatal("DataBase is not declared")
+		logging.Fatal("DataBase is not declared")
 	}
 	if cfg.SelfURL == "" {
-		log.Fatal("WebService is not declared")
+		logging.Fatal("WebService is not declared")
 	}
 	if cfg.TimeZone == "" {
-		log.Fatal("TimeZone is empty")
+		logging.Fatal("TimeZone is empty")
 	}
 	if cfg.WebhookSecret == "" {
-		log.Fatal("WebHookSecret is empty")
+		logging.Fatal("WebHookSecret is empty")
 	}
 
+	for _, s := range strings.Split(os.Getenv("ADMIN_CHAT_IDS"), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			logging.Fatal("ADMIN_CHAT_IDS: bad chat id", "value", s)
+		}
+		cfg.AdminChatIDs = append(cfg.AdminChatIDs, id)
+	}
+	if v := os.Getenv("JOB_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			logging.Fatal("JOB_MAX_ATTEMPTS: bad value", "value", v)
+		}
+		cfg.JobMaxAttempts = n
+	}
+	if v := os.Getenv("CATCHUP_POLICY"); v != "" {
+		switch v {
+		case "all", "recent", "digest":
+			cfg.CatchUpPolicy = v
+		default:
+			logging.Fatal("CATCHUP_POLICY: want all | recent | digest", "value", v)
+		}
+	}
+	if v := os.Getenv("CATCHUP_MAX_LATE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logging.Fatal("CATCHUP_MAX_LATE: bad duration", "value", v)
+		}
+		cfg.CatchUpMaxLate = d
+	}
+	if v, ok := os.LookupEnv("METRICS_ADDR"); ok {
+		cfg.MetricsAddr = v
+	}
+	cfg.HistoryRetention = 90 * 24 * time.Hour
+	if v := os.Getenv("HISTORY_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logging.Fatal("HISTORY_RETENTION_DAYS: bad value", "value", v)
+		}
+		cfg.HistoryRetention = time.Duration(n) * 24 * time.Hour
+	}
+
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		if v != "text" && v != "json" {
+			logging.Fatal("LOG_FORMAT: want text | json", "value", v)
+		}
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(v)); err != nil {
+			logging.Fatal("LOG_LEVEL: want debug | info | warn | error", "value", v)
+		}
+		cfg.LogLevel = v
+	}
+	cfg.LogMessageText = os.Getenv("LOG_MESSAGE_TEXT") == "true"
+
 	return cfg
 }
+
+func (c Config) IsAdmin(chatID int64) bool {
+	for _, id := range c.AdminChatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
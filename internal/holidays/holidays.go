@@ -0,0 +1,148 @@
+// Package holidays — производственный календарь РФ: праздничные и
+// перенесённые нерабочие дни.
+//
+// Для лет, по которым известно постановление правительства о переносе
+// выходных, дни берутся из таблицы decrees. Для остальных лет календарь
+// строится по ст. 112 ТК РФ: нерабочие праздничные дни, а праздник,
+// выпавший на выходной (кроме январских), переносится на следующий рабочий
+// день.
+package holidays
+
+import "time"
+
+// fixed — нерабочие праздничные дни (месяц, день).
+var fixed = []struct {
+	month time.Month
+	day   int
+}{
+	{time.January, 1}, {time.January, 2}, {time.January, 3}, {time.January, 4},
+	{time.January, 5}, {time.January, 6}, {time.January, 7}, {time.January, 8},
+	{time.February, 23},
+	{time.March, 8},
+	{time.May, 1},
+	{time.May, 9},
+	{time.June, 12},
+	{time.November, 4},
+}
+
+// decree — нерабочие будни года (праздники и перенесённые выходные) и
+// рабочие субботы и воскресенья по постановлению о переносе выходных.
+type decree struct {
+	off  []string
+	work []string
+}
+
+var decrees = map[int]decree{
+	2025: {
+		off: []string{
+			"2025-01-01", "2025-01-02", "2025-01-03", "2025-01-06", "2025-01-07", "2025-01-08",
+			"2025-05-01", "2025-05-02", "2025-05-08", "2025-05-09",
+			"2025-06-12", "2025-06-13",
+			"2025-11-03", "2025-11-04",
+			"2025-12-31",
+		},
+		work: []string{"2025-11-01"},
+	},
+	2026: {
+		off: []string{
+			"2026-01-01", "2026-01-02", "2026-01-05", "2026-01-06", "2026-01-07", "2026-01-08", "2026-01-09",
+			"2026-02-23",
+			"2026-03-09",
+			"2026-05-01", "2026-05-11",
+			"2026-06-12",
+			"2026-11-04",
+			"2026-12-31",
+		},
+	},
+}
+
+type date struct {
+	y int
+	m time.Month
+	d int
+}
+
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+var (
+	decreeOff  = map[date]bool{}
+	decreeWork = map[date]bool{}
+)
+
+func init() {
+	for _, dec := range decrees {
+		for _, s := range dec.off {
+			decreeOff[dateOf(mustDate(s))] = true
+		}
+		for _, s := range dec.work {
+			decreeWork[dateOf(mustDate(s))] = true
+		}
+	}
+}
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic("holidays: bad date " + s)
+	}
+	return t
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+func isFixed(t time.Time) bool {
+	_, m, d := t.Date()
+	for _, f := range fixed {
+		if f.month == m && f.day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// DayOff сообщает, что дата t — праздник или перенесённый выходной, то есть
+// нерабочий день сверх обычных суббот и воскресений. Учитывается только
+// календарная дата t.
+func DayOff(t time.Time) bool {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if isFixed(t) {
+		return true
+	}
+	if _, ok := decrees[t.Year()]; ok {
+		return decreeOff[dateOf(t)]
+	}
+	return !isWeekend(t) && shiftedHoliday(t)
+}
+
+// Working сообщает, что дата t — рабочий день: будний день, не праздник и
+// не перенесённый выходной, либо рабочая суббота по постановлению.
+func Working(t time.Time) bool {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if decreeWork[dateOf(t)] {
+		return true
+	}
+	return !isWeekend(t) && !DayOff(t)
+}
+
+// shiftedHoliday — будний день t получил выходной по ст. 112 ТК РФ: на него
+// перенесён праздник, выпавший на предшествующие выходные.
+func shiftedHoliday(t time.Time) bool {
+	// сколько праздников на выходных ждут переноса к моменту t
+	pending := 0
+	for d := t.AddDate(0, 0, -14); d.Before(t); d = d.AddDate(0, 0, 1) {
+		switch {
+		case isFixed(d) && isWeekend(d) && d.Month() != time.January:
+			pending++
+		case isFixed(d) && isWeekend(d):
+			// январские праздники на выходных не переносятся
+		case pending > 0 && !isWeekend(d) && !isFixed(d):
+			pending--
+		}
+	}
+	return pending > 0
+}
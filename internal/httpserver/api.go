@@ -0,0 +1,667 @@
+package httpserver
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// api — JSON API /api/v1 поверх тех же репозиториев, что использует бот.
+// Доступ по токену чата: Authorization: Bearer <token>, токен выдаёт /token.
+type api struct {
+	store *storage.Storage
+}
+
+type ctxKey int
+
+const chatIDKey ctxKey = iota
+
+func (a *api) routes(r chi.Router) {
+	r.Route("/chats/{chatID}", func(r chi.Router) {
+		r.Use(a.auth)
+
+		r.Get("/reminders", a.listReminders)
+		r.Post("/reminders", a.createReminder)
+		r.Get("/reminders/{id}", a.getReminder)
+		r.Patch("/reminders/{id}", a.patchReminder)
+		r.Delete("/reminders/{id}", a.deleteReminder)
+
+		r.Get("/schedule", a.getSchedule)
+		r.Put("/schedule", a.putSchedule)
+
+		r.Get("/settings", a.getSettings)
+		r.Patch("/settings", a.patchSettings)
+	})
+}
+
+func (a *api) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		chatID, err := strconv.ParseInt(chi.URLParam(r, "chatID"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "bad chat id")
+			return
+		}
+		owner, found, err := a.store.Tokens().ChatByToken(r.Context(), token)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("api auth failed", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if owner != chatID {
+			writeError(w, http.StatusForbidden, "token does not belong to this chat")
+			return
+		}
+		ctx := context.WithValue(r.Context(), chatIDKey, chatID)
+		ctx = logging.WithContext(ctx, logging.FromContext(ctx).With("chat_id", chatID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func chatIDFrom(r *http.Request) int64 { return r.Context().Value(chatIDKey).(int64) }
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("api encode failed", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "bad json: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (a *api) chatTZ(ctx context.Context, chatID int64) string {
+	cs, _ := a.store.ChatSettings().Get(ctx, chatID)
+	if cs.TimeZone == "" {
+		return "UTC"
+	}
+	return cs.TimeZone
+}
+
+// ---- reminders ----
+
+type reminderJSON struct {
+	ID          int64      `json:"id"`
+	Message     string     `json:"message"`
+	EventTime   *time.Time `json:"event_time"`
+	Rule        *string    `json:"rule"`
+	NextReport  *time.Time `json:"next_report"`
+	LeadMinutes []int      `json:"lead_minutes"`
+	Persistent  bool       `json:"persistent"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func toReminderJSON(m storage.Reminder) reminderJSON {
+	return reminderJSON{
+		ID:          m.ID,
+		Message:     m.Message,
+		EventTime:   m.EventTime,
+		Rule:        m.ReminderRule,
+		NextReport:  m.NextReport,
+		LeadMinutes: m.ReminderOffsets,
+		Persistent:  m.Persistent,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+// reminderInput — тело POST/PATCH. Время задаётся одним из способов:
+// text — целиком фраза, как в чате («завтра 10:00 встреча за час»);
+// when — только время в свободной форме, текст берётся из message;
+// event_time — точное время в RFC 3339.
+type reminderInput struct {
+	Text        *string    `json:"text"`
+	Message     *string    `json:"message"`
+	When        *string    `json:"when"`
+	EventTime   *time.Time `json:"event_time"`
+	LeadMinutes []int      `json:"lead_minutes"`
+	Persistent  *bool      `json:"persistent"`
+}
+
+var errPastEventTime = errors.New("event_time must be in the future")
+
+// apply переносит поля запроса в напоминание. Возвращает true, если изменилось
+// время срабатывания и job'ы нужно перепланировать.
+func (in reminderInput) apply(m *storage.Reminder, tz string, now time.Time) (bool, error) {
+	reschedule := false
+	var p *timeparse.Parsed
+	switch {
+	case in.Text != nil:
+		parsed, err := timeparse.ParseRU(*in.Text, tz, now)
+		if err != nil {
+			return false, err
+		}
+		p = parsed
+		m.Message = p.Title
+	case in.When != nil:
+		parsed, err := timeparse.ParseRU(*in.When, tz, now)
+		if err != nil {
+			return false, err
+		}
+		p = parsed
+	case in.EventTime != nil:
+		if in.EventTime.Before(now) {
+			return false, errPastEventTime
+		}
+		due := in.EventTime.UTC()
+		p = &timeparse.Parsed{DueUTC: &due, LeadOffsets: m.ReminderOffsets}
+	}
+	if p != nil {
+		reschedule = true
+		if p.DueUTC != nil {
+			due := p.DueUTC.UTC()
+			m.EventTime, m.ReminderRule, m.NextReport = &due, nil, nil
+		} else {
+			next := storage.NextFromWeeklyRRULE(*p.RRULE, tz, now)
+			m.EventTime, m.ReminderRule, m.NextReport = nil, p.RRULE, &next
+		}
+		if in.Text != nil || in.When != nil {
+			m.ReminderOffsets = p.LeadOffsets
+			if p.Persistent {
+				m.Persistent = true
+				m.NagInterval = p.NagEvery
+			}
+		}
+	}
+	if in.Message != nil {
+		m.Message = strings.TrimSpace(*in.Message)
+	}
+	if in.LeadMinutes != nil {
+		for _, o := range in.LeadMinutes {
+			if o < 0 {
+				return false, errors.New("lead_minutes must not be negative")
+			}
+		}
+		m.ReminderOffsets = timeparse.NormalizeOffsets(in.LeadMinutes)
+		reschedule = true
+	}
+	if in.Persistent != nil {
+		m.Persistent = *in.Persistent
+	}
+	if m.Persistent {
+		if m.NagInterval <= 0 {
+			m.NagInterval = storage.DefaultNagInterval
+		}
+		if m.NagMax <= 0 {
+			m.NagMax = storage.DefaultNagMax
+		}
+	}
+	m.ReminderOffsets = timeparse.NormalizeOffsets(m.ReminderOffsets)
+
+	if m.Message == "" {
+		return false, errors.New("message is required")
+	}
+	if m.EventTime == nil && m.ReminderRule == nil {
+		return false, errors.New("one of text, when or event_time is required")
+	}
+	return reschedule, nil
+}
+
+// inputStatus — код ответа на ошибку apply: время в прошлом — 400,
+// остальное — 422.
+func inputStatus(err error) int {
+	if errors.Is(err, errPastEventTime) {
+		return http.StatusBadRequest
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func (a *api) schedule(ctx context.Context, m storage.Reminder) error {
+	occ := m.EventTime
+	if occ == nil {
+		occ = m.NextReport
+	}
+	if occ == nil {
+		return nil
+	}
+	return a.store.Jobs().CreateForEvent(ctx, m.ID, *occ, m.ReminderOffsets, time.Now().UTC())
+}
+
+func (a *api) listReminders(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	q := r.URL.Query()
+
+	from := time.Now().UTC()
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "from must be RFC 3339")
+			return
+		}
+		from = t
+	}
+	var to *time.Time
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to must be RFC 3339")
+			return
+		}
+		to = &t
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			writeError(w, http.StatusBadRequest, "limit must be 1..200")
+			return
+		}
+		limit = n
+	}
+
+	items, err := a.store.Reminders().GetUpcoming(r.Context(), chatID, from, to, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api list reminders failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	out := make([]reminderJSON, 0, len(items))
+	for _, m := range items {
+		out = append(out, toReminderJSON(m))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *api) createReminder(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	var in reminderInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	m := storage.Reminder{ChatID: chatID}
+	if _, err := in.apply(&m, a.chatTZ(r.Context(), chatID), time.Now()); err != nil {
+		writeError(w, inputStatus(err), err.Error())
+		return
+	}
+	id, err := a.store.Reminders().Create(r.Context(), &m)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api create reminder failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	m.ID = id
+	if err := a.schedule(r.Context(), m); err != nil {
+		logging.FromContext(r.Context()).Error("api schedule jobs failed", "reminder_id", id, logging.Err(err))
+	}
+	a.writeReminder(w, r, chatID, id, http.StatusCreated)
+}
+
+func (a *api) writeReminder(w http.ResponseWriter, r *http.Request, chatID, id int64, status int) {
+	m, err := a.store.Reminders().Get(r.Context(), chatID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "reminder not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api get reminder failed", "reminder_id", id, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, status, toReminderJSON(m))
+}
+
+func reminderID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "reminder not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func (a *api) getReminder(w http.ResponseWriter, r *http.Request) {
+	id, ok := reminderID(w, r)
+	if !ok {
+		return
+	}
+	a.writeReminder(w, r, chatIDFrom(r), id, http.StatusOK)
+}
+
+func (a *api) patchReminder(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	id, ok := reminderID(w, r)
+	if !ok {
+		return
+	}
+	var in reminderInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	m, err := a.store.Reminders().Get(r.Context(), chatID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "reminder not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api get reminder failed", "reminder_id", id, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	wasPersistent := m.Persistent
+	reschedule, err := in.apply(&m, a.chatTZ(r.Context(), chatID), time.Now())
+	if err != nil {
+		writeError(w, inputStatus(err), err.Error())
+		return
+	}
+	if err := a.store.Reminders().Update(r.Context(), &m); err != nil {
+		logging.FromContext(r.Context()).Error("api update reminder failed", "reminder_id", id, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if reschedule {
+		// новое время — новое вхождение: неотправленные job'ы, включая повторы,
+		// удаляются, а отметка «Готово» к нему уже не относится
+		if err := a.store.Jobs().DeletePending(r.Context(), id); err != nil {
+			logging.FromContext(r.Context()).Error("api delete pending jobs failed", "reminder_id", id, logging.Err(err))
+		}
+		if m.AcknowledgedAt != nil {
+			if err := a.store.Reminders().ClearAck(r.Context(), id); err != nil {
+				logging.FromContext(r.Context()).Error("api clear ack failed", "reminder_id", id, logging.Err(err))
+			}
+		}
+		if err := a.schedule(r.Context(), m); err != nil {
+			logging.FromContext(r.Context()).Error("api schedule jobs failed", "reminder_id", id, logging.Err(err))
+		}
+	} else if wasPersistent && !m.Persistent {
+		if err := a.store.Jobs().SkipNags(r.Context(), id, "persistent disabled"); err != nil {
+			logging.FromContext(r.Context()).Error("api skip nag jobs failed", "reminder_id", id, logging.Err(err))
+		}
+	}
+	a.writeReminder(w, r, chatID, id, http.StatusOK)
+}
+
+func (a *api) deleteReminder(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	id, ok := reminderID(w, r)
+	if !ok {
+		return
+	}
+	found, err := a.store.Reminders().Delete(r.Context(), chatID, id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api delete reminder failed", "reminder_id", id, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "reminder not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---- schedule ----
+
+type weeklyEntryJSON struct {
+	ID      int64   `json:"id,omitempty"`
+	Weekday int     `json:"weekday"`
+	Start   string  `json:"start"`
+	End     *string `json:"end"`
+	Title   string  `json:"title"`
+	// parity: 0 — каждую неделю, 1 — нечётные (числитель), 2 — чётные
+	Parity int `json:"parity,omitempty"`
+	// valid_from, valid_to — YYYY-MM-DD включительно
+	ValidFrom *string `json:"valid_from,omitempty"`
+	ValidTo   *string `json:"valid_to,omitempty"`
+	Location  string  `json:"location,omitempty"`
+	Notes     string  `json:"notes,omitempty"`
+	// владелец записи в группе, только для чтения
+	UserID *int64 `json:"user_id,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+func toWeeklyJSON(entries []storage.WeeklyEntry) []weeklyEntryJSON {
+	out := make([]weeklyEntryJSON, 0, len(entries))
+	for _, e := range entries {
+		j := weeklyEntryJSON{ID: e.ID, Weekday: e.Weekday, Start: e.StartTime.Format("15:04"), Title: e.Title,
+			Parity: e.Parity, Location: e.Location, Notes: e.Notes, UserID: e.UserID, Owner: e.OwnerName}
+		if e.EndTime != nil {
+			end := e.EndTime.Format("15:04")
+			j.End = &end
+		}
+		if e.ValidFrom != nil {
+			d := e.ValidFrom.Format(time.DateOnly)
+			j.ValidFrom = &d
+		}
+		if e.ValidTo != nil {
+			d := e.ValidTo.Format(time.DateOnly)
+			j.ValidTo = &d
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
+func (a *api) getSchedule(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	entries, err := a.store.Schedule().List(r.Context(), chatID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api list schedule failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, toWeeklyJSON(entries))
+}
+
+// putSchedule заменяет общее расписание чата: либо text в формате
+// /timetable set, либо список entries. Личные записи участников группы
+// не затрагиваются.
+func (a *api) putSchedule(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	var in struct {
+		Text    *string            `json:"text"`
+		Entries *[]weeklyEntryJSON `json:"entries"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	// пустое тело не должно стирать расписание: очистка — явный "entries": []
+	if in.Text == nil && in.Entries == nil {
+		writeError(w, http.StatusBadRequest, "one of text or entries is required")
+		return
+	}
+	var items []weeklyEntryJSON
+	if in.Entries != nil {
+		items = *in.Entries
+	}
+
+	var entries []storage.WeeklyEntry
+	if in.Text != nil {
+		parsed, err := timeparse.ParseWeeklyEntries(*in.Text)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		entries = parsed
+	}
+	for i, e := range items {
+		if e.Weekday < 1 || e.Weekday > 7 {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: weekday must be 1..7")
+			return
+		}
+		if strings.TrimSpace(e.Title) == "" {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: title is required")
+			return
+		}
+		span := e.Start
+		if e.End != nil {
+			span += "-" + *e.End
+		}
+		st, et, err := timeparse.ParseTimeRange(span)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: "+err.Error())
+			return
+		}
+		if e.Parity < storage.ParityAny || e.Parity > storage.ParityEven {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: parity must be 0, 1 or 2")
+			return
+		}
+		from, err := parseDateOpt(e.ValidFrom)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: valid_from must be YYYY-MM-DD")
+			return
+		}
+		to, err := parseDateOpt(e.ValidTo)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "entries["+strconv.Itoa(i)+"]: valid_to must be YYYY-MM-DD")
+			return
+		}
+		entries = append(entries, storage.WeeklyEntry{
+			Weekday: e.Weekday, StartTime: st, EndTime: et, Title: strings.TrimSpace(e.Title),
+			Parity: e.Parity, ValidFrom: from, ValidTo: to,
+			Location: strings.TrimSpace(e.Location), Notes: strings.TrimSpace(e.Notes),
+		})
+	}
+
+	if err := a.store.Schedule().Set(r.Context(), chatID, nil, entries); err != nil {
+		logging.FromContext(r.Context()).Error("api set schedule failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	a.getSchedule(w, r)
+}
+
+func parseDateOpt(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, *s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ---- settings ----
+
+type settingsJSON struct {
+	TimeZone        string  `json:"time_zone"`
+	DailyReportTime *string `json:"daily_report_time"`
+	QuietFrom       *string `json:"quiet_from"`
+	QuietTo         *string `json:"quiet_to"`
+	QuietMode       string  `json:"quiet_mode"`
+}
+
+func hhmm(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("15:04")
+	return &s
+}
+
+func (a *api) getSettings(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	cs, err := a.store.ChatSettings().Get(r.Context(), chatID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		logging.FromContext(r.Context()).Error("api get settings failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if cs.QuietMode == "" {
+		cs.QuietMode = storage.QuietDelay
+	}
+	writeJSON(w, http.StatusOK, settingsJSON{
+		TimeZone:        cs.TimeZone,
+		DailyReportTime: hhmm(cs.DailyReportTime),
+		QuietFrom:       hhmm(cs.QuietFrom),
+		QuietTo:         hhmm(cs.QuietTo),
+		QuietMode:       cs.QuietMode,
+	})
+}
+
+// patchSettings меняет только переданные поля. daily_report_time: "HH:MM"
+// или "off"; quiet: "HH:MM-HH:MM" или "off". Сначала проверяются все поля,
+// потом они записываются вместе: при ошибке в любом ничего не меняется.
+func (a *api) patchSettings(w http.ResponseWriter, r *http.Request) {
+	chatID := chatIDFrom(r)
+	var in struct {
+		TimeZone        *string `json:"time_zone"`
+		DailyReportTime *string `json:"daily_report_time"`
+		Quiet           *string `json:"quiet"`
+		QuietMode       *string `json:"quiet_mode"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	var p storage.SettingsPatch
+	if in.TimeZone != nil {
+		tz := strings.TrimSpace(*in.TimeZone)
+		if _, ok := storage.LoadFixedUTC(tz); !ok {
+			if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+				writeError(w, http.StatusUnprocessableEntity, "unknown time_zone")
+				return
+			}
+		}
+		p.TimeZone = &tz
+	}
+	if in.DailyReportTime != nil {
+		p.SetDaily = true
+		if v := strings.TrimSpace(*in.DailyReportTime); v != "off" && v != "" {
+			parsed, err := time.Parse("15:04", v)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "daily_report_time must be HH:MM or off")
+				return
+			}
+			p.Daily = &parsed
+		}
+	}
+	if in.Quiet != nil {
+		p.SetQuiet = true
+		if v := strings.TrimSpace(*in.Quiet); v != "off" && v != "" {
+			st, et, err := timeparse.ParseTimeRange(v)
+			if err != nil || et == nil {
+				writeError(w, http.StatusUnprocessableEntity, "quiet must be HH:MM-HH:MM or off")
+				return
+			}
+			p.QuietFrom, p.QuietTo = &st, et
+		}
+	}
+	if in.QuietMode != nil {
+		mode := *in.QuietMode
+		if mode == "" {
+			mode = storage.QuietDelay
+		}
+		if mode != storage.QuietDelay && mode != storage.QuietSilent {
+			writeError(w, http.StatusUnprocessableEntity, "quiet_mode must be delay or silent")
+			return
+		}
+		p.QuietMode = &mode
+	}
+
+	if err := a.store.ChatSettings().Patch(r.Context(), chatID, p); err != nil {
+		logging.FromContext(r.Context()).Error("api patch settings failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	a.getSettings(w, r)
+}
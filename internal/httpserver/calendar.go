@@ -0,0 +1,242 @@
+package httpserver
+
+import (
+	"TelegramBot/internal/holidays"
+	"TelegramBot/internal/ical"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var icalDays = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// calendarHandler отдаёт ICS-ленту чата по секретной ссылке из /calendar.
+func calendarHandler(store *storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cs, found, err := store.ChatSettings().ByCalendarToken(ctx, chi.URLParam(r, "token"))
+		if err != nil {
+			logging.FromContext(ctx).Error("calendar token lookup failed", logging.Err(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		reminders, err := store.Reminders().ListAll(ctx, cs.ChatID)
+		if err != nil {
+			logging.FromContext(ctx).Error("calendar reminders failed", "chat_id", cs.ChatID, logging.Err(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		entries, err := store.Schedule().List(ctx, cs.ChatID)
+		if err != nil {
+			logging.FromContext(ctx).Error("calendar schedule failed", "chat_id", cs.ChatID, logging.Err(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		exs, err := store.Exceptions().Between(ctx, cs.ChatID, now.AddDate(0, 0, -7), now.AddDate(1, 0, 0))
+		if err != nil {
+			logging.FromContext(ctx).Error("calendar exceptions failed", "chat_id", cs.ChatID, logging.Err(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		cal := buildCalendar(cs, reminders, entries, exs, r.Host, now)
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+		_, _ = w.Write(cal.Render(time.Now()))
+	}
+}
+
+// calendarZone возвращает TZID и зону для ленты. Зоны вида UTC+3 не имеют
+// IANA-имени, поэтому для них время пишется в UTC.
+func calendarZone(tz string) (string, *time.Location) {
+	if _, fixed := storage.LoadFixedUTC(tz); fixed || tz == "" {
+		return "", time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", time.UTC
+	}
+	return tz, loc
+}
+
+func buildCalendar(cs storage.ChatSettings, reminders []storage.Reminder, entries []storage.WeeklyEntry,
+	exs []storage.ScheduleException, host string, now time.Time) ical.Calendar {
+	tzid, loc := calendarZone(cs.TimeZone)
+	cal := ical.Calendar{Name: "Секретарь", TZID: tzid, Loc: loc}
+	// праздники берутся в том же окне, что и исключения в calendarHandler
+	var offDays []time.Time
+	if cs.Holidays == storage.HolidaysTimetable || cs.Holidays == storage.HolidaysAll {
+		offDays = holidayDates(now.AddDate(0, 0, -7), now.AddDate(1, 0, 0), loc)
+	}
+
+	for _, m := range reminders {
+		start := m.EventTime
+		if start == nil {
+			start = m.NextReport
+		}
+		if start == nil {
+			continue
+		}
+		e := ical.Event{
+			UID:     fmt.Sprintf("reminder-%d@%s", m.ID, host),
+			Summary: m.Message,
+			Start:   *start,
+			Alarms:  m.ReminderOffsets,
+		}
+		if m.ReminderRule != nil {
+			if byday := ruleValue(*m.ReminderRule, "BYDAY"); byday != "" {
+				e.RRule = "FREQ=WEEKLY;BYDAY=" + byday
+			} else if ruleValue(*m.ReminderRule, "FREQ") == "DAILY" {
+				e.RRule = "FREQ=DAILY"
+			}
+		}
+		// в режиме «all» повторяющиеся напоминания в праздники не приходят
+		if e.RRule != "" && cs.Holidays == storage.HolidaysAll {
+			first := start.In(loc)
+			for _, d := range offDays {
+				at := time.Date(d.Year(), d.Month(), d.Day(), first.Hour(), first.Minute(), 0, 0, loc)
+				if !at.Before(first) && ruleFiresOn(e.RRule, d) {
+					e.ExDates = append(e.ExDates, at)
+				}
+			}
+		}
+		cal.Events = append(cal.Events, e)
+	}
+
+	// Расписание начинается с понедельника текущей недели, чтобы в календаре
+	// были видны и уже прошедшие дни.
+	today := now.In(loc)
+	monday := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc).
+		AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	for _, en := range entries {
+		day := monday.AddDate(0, 0, en.Weekday-1)
+		if en.ValidFrom != nil {
+			from := time.Date(en.ValidFrom.Year(), en.ValidFrom.Month(), en.ValidFrom.Day(), 0, 0, 0, 0, loc)
+			for day.Before(from) {
+				day = day.AddDate(0, 0, 7)
+			}
+		}
+		rule := "FREQ=WEEKLY;BYDAY=" + icalDays[en.Weekday-1]
+		if en.Parity != storage.ParityAny {
+			// через неделю, начиная с недели нужной чётности: WeekParity
+			// считает недели подряд, так что INTERVAL=2 с ней не расходится
+			if storage.WeekParity(day, en.ValidFrom) != en.Parity {
+				day = day.AddDate(0, 0, 7)
+			}
+			rule += ";INTERVAL=2"
+		}
+		if en.ValidTo != nil {
+			until := time.Date(en.ValidTo.Year(), en.ValidTo.Month(), en.ValidTo.Day(), 23, 59, 59, 0, loc)
+			if until.Before(day) {
+				continue
+			}
+			rule += ";UNTIL=" + until.UTC().Format("20060102T150405Z")
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), en.StartTime.Hour(), en.StartTime.Minute(), 0, 0, loc)
+		e := ical.Event{
+			UID:         fmt.Sprintf("schedule-%d@%s", en.ID, host),
+			Summary:     en.Title,
+			Description: en.Notes,
+			Location:    en.Location,
+			Start:       start,
+			RRule:       rule,
+		}
+		if en.EndTime != nil {
+			end := time.Date(day.Year(), day.Month(), day.Day(), en.EndTime.Hour(), en.EndTime.Minute(), 0, 0, loc)
+			if end.Before(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			e.End = &end
+		}
+		// отменённые разовым исключением дни
+		for _, x := range exs {
+			if x.Cancels(en) {
+				e.ExDates = appendExDate(e.ExDates, time.Date(x.Date.Year(), x.Date.Month(), x.Date.Day(),
+					en.StartTime.Hour(), en.StartTime.Minute(), 0, 0, loc))
+			}
+		}
+		// праздники, если чат их учитывает
+		for _, d := range offDays {
+			if !d.Before(day) && en.ActiveOn(d) {
+				e.ExDates = appendExDate(e.ExDates, time.Date(d.Year(), d.Month(), d.Day(),
+					en.StartTime.Hour(), en.StartTime.Minute(), 0, 0, loc))
+			}
+		}
+		cal.Events = append(cal.Events, e)
+	}
+
+	// разовые занятия
+	for _, x := range exs {
+		if x.Kind != storage.ExceptionAdd || x.StartTime == nil {
+			continue
+		}
+		start := time.Date(x.Date.Year(), x.Date.Month(), x.Date.Day(), x.StartTime.Hour(), x.StartTime.Minute(), 0, 0, loc)
+		e := ical.Event{
+			UID:     fmt.Sprintf("schedule-once-%d@%s", x.ID, host),
+			Summary: x.Title,
+			Start:   start,
+		}
+		if x.EndTime != nil {
+			end := time.Date(x.Date.Year(), x.Date.Month(), x.Date.Day(), x.EndTime.Hour(), x.EndTime.Minute(), 0, 0, loc)
+			if end.Before(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			e.End = &end
+		}
+		cal.Events = append(cal.Events, e)
+	}
+	return cal
+}
+
+// holidayDates — нерабочие дни производственного календаря с from по to
+// (полночь в зоне loc).
+func holidayDates(from, to time.Time, loc *time.Location) []time.Time {
+	var out []time.Time
+	from = from.In(loc)
+	for d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); d.Before(to); d = d.AddDate(0, 0, 1) {
+		if holidays.DayOff(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// ruleFiresOn сообщает, приходится ли на день d повтор по правилу ленты
+// (FREQ=DAILY или FREQ=WEEKLY;BYDAY=...).
+func ruleFiresOn(rule string, d time.Time) bool {
+	if byday := ruleValue(rule, "BYDAY"); byday != "" {
+		return strings.Contains(","+byday+",", ","+icalDays[storage.ISOWeekday(d)-1]+",")
+	}
+	return ruleValue(rule, "FREQ") == "DAILY"
+}
+
+// appendExDate добавляет исключённую дату, если её ещё нет: день может быть
+// и отменён исключением, и праздничным.
+func appendExDate(list []time.Time, t time.Time) []time.Time {
+	for _, x := range list {
+		if x.Equal(t) {
+			return list
+		}
+	}
+	return append(list, t)
+}
+
+func ruleValue(rule, key string) string {
+	for _, p := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package httpserver
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	BotApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// notifierStaleAfter — цикл уведомлений просыпается минимум раз в 30 секунд
+	// (дайджесты), так что двухминутная тишина означает, что он завис.
+	notifierStaleAfter = 2 * time.Minute
+	// webhookInfoTTL ограничивает частоту getWebhookInfo при частых пробах.
+	webhookInfoTTL = 30 * time.Second
+	// webhookErrorWindow — насколько свежая ошибка доставки вебхука считается проблемой.
+	webhookErrorWindow = 10 * time.Minute
+)
+
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+	statusDown     = "down"
+)
+
+// Health — зависимости проверок /healthz и /ready.
+type Health struct {
+	Store      *storage.Storage
+	Bot        *BotApi.BotAPI
+	Notifier   interface{ LastTick() time.Time }
+	WebhookURL string
+
+	mu        sync.Mutex
+	info      BotApi.WebhookInfo
+	infoErr   error
+	infoFetch time.Time
+}
+
+type healthCheck struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Details   any    `json:"details,omitempty"`
+
+	// cause — исходный текст ошибки (ответ Telegram, ошибка драйвера БД).
+	// Наружу не отдаётся: только в лог и в подробный отчёт.
+	cause string
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Time   time.Time              `json:"time"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// healthz — полная проверка: БД, цикл уведомлений и вебхук Telegram.
+// Ручка публичная, поэтому отдаёт только статусы; причины сбоев пишутся в лог.
+func (h *Health) healthz(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, true, false)
+}
+
+// Details — то же, что /healthz, но с текстом ошибок, адресом вебхука и
+// статистикой пула соединений. Монтируется только на внутренний листенер.
+func (h *Health) Details(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, true, true)
+}
+
+// ready — готовность принимать трафик: БД и цикл уведомлений. Telegram не
+// проверяется, чтобы его сбои не выводили инстанс из балансировки.
+func (h *Health) ready(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, false, false)
+}
+
+func (h *Health) respond(w http.ResponseWriter, r *http.Request, withTelegram, detailed bool) {
+	rep := healthReport{Status: statusOK, Time: time.Now().UTC(), Checks: map[string]healthCheck{}}
+	rep.Checks["database"] = h.checkDB(r.Context())
+	rep.Checks["notifier"] = h.checkNotifier()
+	if withTelegram {
+		rep.Checks["telegram"] = h.checkTelegram()
+	}
+
+	for name, c := range rep.Checks {
+		if c.Status != statusOK {
+			logging.FromContext(r.Context()).Warn("health check failed",
+				"check", name, "status", c.Status, "reason", c.Error, "cause", c.cause)
+		}
+		if detailed {
+			if c.cause != "" {
+				c.Error += ": " + c.cause
+			}
+		} else {
+			c.Details = nil
+		}
+		rep.Checks[name] = c
+
+		switch {
+		case c.Status == statusDown:
+			rep.Status = statusDown
+		case c.Status == statusDegraded && rep.Status == statusOK:
+			rep.Status = statusDegraded
+		}
+	}
+	code := http.StatusOK
+	if rep.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, rep)
+}
+
+func (h *Health) checkDB(ctx context.Context) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := h.Store.Now(ctx)
+	c := healthCheck{Status: statusOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status, c.Error, c.cause = statusDown, "database is unreachable", err.Error()
+	}
+	st := h.Store.PoolStat()
+	c.Details = map[string]any{
+		"total_conns":         st.TotalConns(),
+		"idle_conns":          st.IdleConns(),
+		"acquired_conns":      st.AcquiredConns(),
+		"max_conns":           st.MaxConns(),
+		"acquire_count":       st.AcquireCount(),
+		"empty_acquire_count": st.EmptyAcquireCount(),
+		"acquire_duration_ms": st.AcquireDuration().Milliseconds(),
+	}
+	return c
+}
+
+func (h *Health) checkNotifier() healthCheck {
+	c := healthCheck{Status: statusOK}
+	if h.Notifier == nil {
+		c.Status, c.Error = statusDegraded, "notifier is not configured"
+		return c
+	}
+	last := h.Notifier.LastTick()
+	if last.IsZero() {
+		c.Status, c.Error = statusDegraded, "notifier has not ticked yet"
+		return c
+	}
+	age := time.Since(last)
+	c.Details = map[string]any{"last_tick": last.UTC(), "age_seconds": int64(age.Seconds())}
+	if age > notifierStaleAfter {
+		c.Status, c.Error = statusDegraded, "notifier loop is stale"
+	}
+	return c
+}
+
+func (h *Health) checkTelegram() healthCheck {
+	start := time.Now()
+	info, err := h.webhookInfo()
+	c := healthCheck{Status: statusOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status, c.Error, c.cause = statusDegraded, "getWebhookInfo failed", err.Error()
+		return c
+	}
+
+	details := map[string]any{
+		"url":                  info.URL,
+		"pending_update_count": info.PendingUpdateCount,
+	}
+	if info.LastErrorDate > 0 {
+		lastErr := time.Unix(int64(info.LastErrorDate), 0).UTC()
+		details["last_error_date"] = lastErr
+		details["last_error_message"] = info.LastErrorMessage
+		if time.Since(lastErr) < webhookErrorWindow {
+			c.Status, c.Error, c.cause = statusDegraded, "recent webhook delivery error", info.LastErrorMessage
+		}
+	}
+	if h.WebhookURL != "" && info.URL != h.WebhookURL {
+		c.Status, c.Error = statusDegraded, "webhook is not set to this instance"
+	}
+	c.Details = details
+	return c
+}
+
+// webhookInfo кэширует getWebhookInfo на webhookInfoTTL.
+func (h *Health) webhookInfo() (BotApi.WebhookInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.infoFetch) < webhookInfoTTL {
+		return h.info, h.infoErr
+	}
+	h.info, h.infoErr = h.Bot.GetWebhookInfo()
+	h.infoFetch = time.Now()
+	return h.info, h.infoErr
+}
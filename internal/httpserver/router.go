@@ -1,9 +1,14 @@
 package httpserver
 
 import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 
 	BotApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -14,8 +19,9 @@ type Router struct {
 	handler http.Handler
 }
 
-func New(secret string, updates chan<- BotApi.Update) *Router {
+func New(secret string, updates chan<- BotApi.Update, store *storage.Storage, health *Health) *Router {
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID, requestLogger)
 
 	router.Post("/webhook", func(w http.ResponseWriter, r *http.Request) {
 		NewWebhookHandler(secret, updates).ServeHTTP(w, r)
@@ -25,6 +31,12 @@ func New(secret string, updates chan<- BotApi.Update) *Router {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	router.Get("/healthz", health.healthz)
+	router.Get("/ready", health.ready)
+
+	router.Route("/api/v1", (&api{store: store}).routes)
+
+	router.Get("/calendar/{token}.ics", calendarHandler(store))
 
 	return &Router{
 		Secret:  secret,
@@ -33,6 +45,24 @@ func New(secret string, updates chan<- BotApi.Update) *Router {
 	}
 }
 
+// requestLogger кладёт в context логгер запроса с request_id. В лог пишется
+// шаблон маршрута, а не путь: в пути /calendar лежит секретный токен.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := slog.With("request_id", middleware.GetReqID(r.Context()))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(logging.WithContext(r.Context(), logger)))
+
+		route := "unmatched"
+		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+			route = rc.RoutePattern()
+		}
+		logger.Debug("http request", "method", r.Method, "route", route,
+			"status", ww.Status(), "duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
 func (rout *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rout.handler.ServeHTTP(w, r)
 }
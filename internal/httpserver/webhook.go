@@ -1,9 +1,12 @@
 package httpserver
 
 import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
+	"TelegramBot/internal/telegram"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 
 	BotApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -43,9 +46,10 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	go func(b []byte) {
 		var upd BotApi.Update
 		if err := json.Unmarshal(b, &upd); err != nil {
-			log.Printf("unmarshal error: %v", err)
+			slog.Warn("webhook update unmarshal failed", logging.Err(err))
 			return
 		}
+		metrics.UpdatesReceived.WithLabelValues(telegram.UpdateKind(upd)).Inc()
 		h.Updates <- upd
 	}(body)
 
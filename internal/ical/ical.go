@@ -0,0 +1,238 @@
+// Package ical собирает календарь в формате iCalendar (RFC 5545).
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         *time.Time
+	// AllDay — событие на весь день (DTSTART;VALUE=DATE).
+	AllDay bool
+	// RRule без DTSTART, например "FREQ=WEEKLY;BYDAY=MO".
+	RRule   string
+	ExDates []time.Time
+	// Alarms — за сколько минут до начала напомнить (0 — в момент начала).
+	Alarms []int
+}
+
+type Calendar struct {
+	Name string
+	// TZID — IANA-зона календаря. Пустая строка — время пишется в UTC.
+	TZID   string
+	Loc    *time.Location
+	Events []Event
+}
+
+const prodID = "-//TelegramBot//Secretary//RU"
+
+// Render возвращает календарь в виде текста text/calendar.
+func (c Calendar) Render(now time.Time) []byte {
+	var w writer
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + Escape(c.Name))
+	}
+	if c.TZID != "" {
+		w.line("X-WR-TIMEZONE:" + c.TZID)
+		writeTimezone(&w, c.TZID, c.Loc, now)
+	}
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + e.UID)
+		w.line("DTSTAMP:" + stamp)
+		if e.AllDay {
+			w.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		} else {
+			w.line("DTSTART" + c.dateTime(e.Start))
+		}
+		if e.End != nil && !e.AllDay {
+			w.line("DTEND" + c.dateTime(*e.End))
+		}
+		if e.RRule != "" {
+			w.line("RRULE:" + e.RRule)
+		}
+		for _, ex := range e.ExDates {
+			w.line("EXDATE" + c.dateTime(ex))
+		}
+		w.line("SUMMARY:" + Escape(e.Summary))
+		if e.Description != "" {
+			w.line("DESCRIPTION:" + Escape(e.Description))
+		}
+		if e.Location != "" {
+			w.line("LOCATION:" + Escape(e.Location))
+		}
+		for _, a := range e.Alarms {
+			w.line("BEGIN:VALARM")
+			w.line("ACTION:DISPLAY")
+			w.line("DESCRIPTION:" + Escape(e.Summary))
+			w.line("TRIGGER:" + Trigger(a))
+			w.line("END:VALARM")
+		}
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// dateTime форматирует значение DTSTART/DTEND вместе с параметрами:
+// ";TZID=Europe/Moscow:20261020T140000" или ":20261020T110000Z".
+func (c Calendar) dateTime(t time.Time) string {
+	if c.TZID == "" || c.Loc == nil {
+		return ":" + t.UTC().Format("20060102T150405Z")
+	}
+	return ";TZID=" + c.TZID + ":" + t.In(c.Loc).Format("20060102T150405")
+}
+
+// Trigger — длительность TRIGGER для напоминания за min минут до начала.
+func Trigger(min int) string {
+	if min <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("-P")
+	if d := min / (24 * 60); d > 0 {
+		fmt.Fprintf(&b, "%dD", d)
+		min %= 24 * 60
+	}
+	if min > 0 {
+		b.WriteString("T")
+		if h := min / 60; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m := min % 60; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+	}
+	return b.String()
+}
+
+// Escape экранирует TEXT-значение по RFC 5545 §3.3.11.
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+type writer struct{ buf bytes.Buffer }
+
+// line пишет строку содержимого, сворачивая её по 75 октетов и не разрывая
+// многобайтовые символы.
+func (w *writer) line(s string) {
+	const limit = 75
+	first := true
+	for len(s) > 0 {
+		n := limit
+		if !first {
+			n = limit - 1
+			w.buf.WriteByte(' ')
+		}
+		if len(s) <= n {
+			w.buf.WriteString(s)
+			break
+		}
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		w.buf.WriteString(s[:n])
+		w.buf.WriteString("\r\n")
+		s = s[n:]
+		first = false
+	}
+	w.buf.WriteString("\r\n")
+}
+
+// writeTimezone описывает зону календаря компонентом VTIMEZONE. Переходы
+// берутся из tzdata за текущий год и повторяются ежегодно по тому же
+// правилу «n-е воскресенье месяца».
+func writeTimezone(w *writer, tzid string, loc *time.Location, now time.Time) {
+	year := now.In(loc).Year()
+	jan := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	transitions := findTransitions(jan, jan.AddDate(1, 0, 0))
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + tzid)
+	if len(transitions) == 0 {
+		name, off := jan.Zone()
+		w.line("BEGIN:STANDARD")
+		w.line("DTSTART:19700101T000000")
+		w.line("TZOFFSETFROM:" + offset(off))
+		w.line("TZOFFSETTO:" + offset(off))
+		w.line("TZNAME:" + name)
+		w.line("END:STANDARD")
+	}
+	for _, t := range transitions {
+		_, before := t.Add(-time.Second).Zone()
+		name, after := t.Zone()
+		kind := "STANDARD"
+		if after > before {
+			kind = "DAYLIGHT"
+		}
+		// DTSTART — локальное время перехода по старому смещению.
+		local := t.UTC().Add(time.Duration(before) * time.Second)
+		w.line("BEGIN:" + kind)
+		w.line("DTSTART:" + local.Format("20060102T150405"))
+		w.line("TZOFFSETFROM:" + offset(before))
+		w.line("TZOFFSETTO:" + offset(after))
+		w.line("TZNAME:" + name)
+		w.line("RRULE:" + yearlyRule(local))
+		w.line("END:" + kind)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+func findTransitions(from, to time.Time) []time.Time {
+	var out []time.Time
+	_, prev := from.Zone()
+	for t := from; t.Before(to); t = t.Add(time.Hour) {
+		_, off := t.Zone()
+		if off == prev {
+			continue
+		}
+		// уточняем момент перехода до секунды
+		lo, hi := t.Add(-time.Hour), t
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.Zone(); o == prev {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		out = append(out, hi)
+		prev = off
+	}
+	return out
+}
+
+func yearlyRule(t time.Time) string {
+	days := []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	n := (t.Day()-1)/7 + 1
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		n = -1
+	}
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(t.Month()), n, days[t.Weekday()])
+}
+
+func offset(sec int) string {
+	sign := '+'
+	if sec < 0 {
+		sign = '-'
+		sec = -sec
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, sec/3600, sec%3600/60)
+}
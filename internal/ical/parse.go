@@ -0,0 +1,264 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotCalendar = errors.New("ical: not an iCalendar file")
+
+// Parse разбирает события VEVENT. zone возвращает зону для TZID; время без
+// TZID и без суффикса "Z" («плавающее») берётся в зоне zone("").
+// Отменённые события и изменённые экземпляры серий (RECURRENCE-ID)
+// пропускаются, как и события с нечитаемым DTSTART.
+func Parse(data []byte, zone func(tzid string) *time.Location) ([]Event, error) {
+	lines := unfold(string(data))
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "BEGIN:VCALENDAR") {
+		return nil, ErrNotCalendar
+	}
+
+	var (
+		events []Event
+		ev     *Event
+		skip   bool
+		alarm  bool
+		dur    time.Duration
+	)
+	for _, raw := range lines {
+		p, ok := parseProp(raw)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			ev, skip, dur = &Event{}, false, 0
+			continue
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if ev != nil && !skip && !ev.Start.IsZero() {
+				if ev.End == nil && dur > 0 {
+					end := ev.Start.Add(dur)
+					ev.End = &end
+				}
+				events = append(events, *ev)
+			}
+			ev = nil
+			continue
+		case ev == nil:
+			continue
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VALARM"):
+			alarm = true
+			continue
+		case p.name == "END" && strings.EqualFold(p.value, "VALARM"):
+			alarm = false
+			continue
+		}
+
+		if alarm {
+			if p.name == "TRIGGER" {
+				if min, ok := triggerMinutes(p); ok {
+					ev.Alarms = append(ev.Alarms, min)
+				}
+			}
+			continue
+		}
+
+		switch p.name {
+		case "UID":
+			ev.UID = p.value
+		case "SUMMARY":
+			ev.Summary = unescape(p.value)
+		case "DESCRIPTION":
+			ev.Description = unescape(p.value)
+		case "LOCATION":
+			ev.Location = unescape(p.value)
+		case "STATUS":
+			skip = skip || strings.EqualFold(p.value, "CANCELLED")
+		case "RECURRENCE-ID":
+			skip = true
+		case "RRULE":
+			ev.RRule = strings.ToUpper(p.value)
+		case "DTSTART":
+			t, allDay, err := parseTime(p, zone)
+			if err != nil {
+				skip = true
+				continue
+			}
+			ev.Start, ev.AllDay = t, allDay
+		case "DTEND":
+			if t, _, err := parseTime(p, zone); err == nil {
+				ev.End = &t
+			}
+		case "DURATION":
+			if d, err := parseDuration(p.value); err == nil {
+				dur = d
+			}
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				q := p
+				q.value = v
+				if t, _, err := parseTime(q, zone); err == nil {
+					ev.ExDates = append(ev.ExDates, t)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+type prop struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfold склеивает свёрнутые строки (RFC 5545 §3.1).
+func unfold(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimPrefix(s, "\ufeff")
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(out) > 0 {
+			out[len(out)-1] += l[1:]
+			continue
+		}
+		if strings.TrimSpace(l) != "" {
+			out = append(out, strings.TrimRight(l, "\r"))
+		}
+	}
+	return out
+}
+
+// parseProp разбирает строку вида NAME;PARAM=VAL;PARAM="V:AL":VALUE.
+func parseProp(line string) (prop, bool) {
+	var (
+		p      = prop{params: map[string]string{}}
+		quoted bool
+		start  int
+		key    string
+		inName = true
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';' || c == ':':
+			part := line[start:i]
+			if inName {
+				p.name = strings.ToUpper(part)
+				inName = false
+			} else if key != "" {
+				p.params[key] = strings.Trim(part, `"`)
+			}
+			key = ""
+			start = i + 1
+			if c == ':' {
+				p.value = line[i+1:]
+				return p, p.name != ""
+			}
+		case c == '=' && !inName && key == "":
+			key = strings.ToUpper(line[start:i])
+			start = i + 1
+		}
+	}
+	return p, false
+}
+
+func parseTime(p prop, zone func(string) *time.Location) (time.Time, bool, error) {
+	v := strings.TrimSpace(p.value)
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, zone(""))
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	loc := zone(p.params["TZID"])
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+// triggerMinutes переводит относительный TRIGGER в «за сколько минут до
+// начала». Абсолютные триггеры и напоминания после начала не поддерживаются.
+func triggerMinutes(p prop) (int, bool) {
+	if p.params["VALUE"] == "DATE-TIME" || p.params["RELATED"] == "END" {
+		return 0, false
+	}
+	d, err := parseDuration(p.value)
+	if err != nil || d > 0 {
+		return 0, false
+	}
+	return int(-d / time.Minute), true
+}
+
+// parseDuration разбирает длительность RFC 5545: [+-]P[nW][nD][T[nH][nM][nS]].
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("ical: bad duration %q", s)
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range s[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("ical: bad duration %q", s)
+		}
+		num = ""
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("ical: bad duration %q", s)
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("ical: bad duration %q", s)
+	}
+	return sign * d, nil
+}
+
+func unescape(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return r.Replace(s)
+}
+
+// RuleParts разбирает RRULE в словарь "FREQ" → "WEEKLY".
+func RuleParts(rule string) map[string]string {
+	out := map[string]string{}
+	for _, p := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			out[strings.ToUpper(k)] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+// Package logging настраивает log/slog и переносит логгер апдейта через
+// context, чтобы update_id и chat_id попадали во все записи обработчика.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"unicode/utf8"
+)
+
+type ctxKey struct{}
+
+// logText — писать ли в лог текст сообщений пользователей. По умолчанию
+// текст скрыт: в нём бывают личные данные.
+var logText bool
+
+// Setup делает slog логгером по умолчанию. format — text или json,
+// level — debug, info, warn или error.
+func Setup(format, level string, withText bool) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(h))
+	logText = withText
+}
+
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext возвращает логгер из ctx или логгер по умолчанию.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Err — единообразное поле ошибки.
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// Text — текст сообщения пользователя; без LOG_MESSAGE_TEXT=true вместо
+// него пишется только длина.
+func Text(s string) slog.Attr {
+	if logText {
+		return slog.String("text", s)
+	}
+	return slog.String("text", fmt.Sprintf("[redacted %d chars]", utf8.RuneCountInString(s)))
+}
+
+// Fatal пишет ошибку и завершает процесс.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
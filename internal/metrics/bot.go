@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Метрики бота. Имена следуют соглашениям Prometheus: *_total для
+// счётчиков, *_seconds для длительностей.
+var (
+	UpdatesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_updates_received_total",
+		Help: "Updates accepted by the webhook, by update type.",
+	}, []string{"type"})
+	UpdatesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_updates_processed_total",
+		Help: "Updates handled by workers, by update type.",
+	}, []string{"type"})
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "telegrambot_handler_duration_seconds",
+		Help:    "Time spent handling an update, by command.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command"})
+	ParseResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_parse_total",
+		Help: "Natural-language reminder parses, by result (ok|error).",
+	}, []string{"result"})
+
+	JobsDue = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "telegrambot_jobs_due_total",
+		Help: "Reminder jobs picked up as due by the notifier.",
+	})
+	JobsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "telegrambot_jobs_sent_total",
+		Help: "Reminder jobs delivered to Telegram.",
+	})
+	JobsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_jobs_failed_total",
+		Help: "Failed reminder deliveries, by outcome (retry|dead).",
+	}, []string{"outcome"})
+	JobLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "telegrambot_job_lag_seconds",
+		Help:    "Delivery time minus report_time of sent jobs.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 900, 3600, 6 * 3600, 24 * 3600},
+	})
+	DigestsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_digests_sent_total",
+		Help: "Digests delivered, by kind (daily|missed).",
+	}, []string{"kind"})
+
+	TelegramErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telegrambot_telegram_api_errors_total",
+		Help: "Failed Telegram Bot API calls, by method and error code.",
+	}, []string{"method", "code"})
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "telegrambot_db_query_duration_seconds",
+		Help:    "Storage call latency, by repository method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
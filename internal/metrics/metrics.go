@@ -0,0 +1,23 @@
+// Package metrics — метрики бота на prometheus/client_golang. Всё
+// регистрируется в реестре по умолчанию, где уже есть стандартные
+// коллекторы go_* и process_*.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler отдаёт все зарегистрированные метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveSince записывает в гистограмму время, прошедшее с start. Удобно
+// в defer: аргументы вычисляются сразу, замер — при выходе.
+func ObserveSince(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
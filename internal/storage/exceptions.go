@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Виды исключений из расписания.
+const (
+	ExceptionCancel = "cancel"
+	ExceptionAdd    = "add"
+)
+
+// ScheduleException — изменение расписания в конкретную дату: отмена
+// записи или разовое занятие.
+type ScheduleException struct {
+	ID        int64
+	ChatID    int64
+	UserID    *int64
+	OwnerName string
+	Date      time.Time
+	Kind      string
+	// StartTime у отмены — время начала отменяемой записи; nil — все записи
+	// с этим названием в этот день.
+	StartTime *time.Time
+	EndTime   *time.Time
+	Title     string
+}
+
+// Cancels сообщает, отменяет ли исключение запись e в свой день.
+func (x ScheduleException) Cancels(e WeeklyEntry) bool {
+	if x.Kind != ExceptionCancel || !strings.EqualFold(x.Title, e.Title) {
+		return false
+	}
+	if (x.UserID == nil) != (e.UserID == nil) || (x.UserID != nil && *x.UserID != *e.UserID) {
+		return false
+	}
+	return x.StartTime == nil || x.StartTime.Format("15:04") == e.StartTime.Format("15:04")
+}
+
+// Entry представляет разовое занятие как запись расписания на его день недели.
+func (x ScheduleException) Entry() WeeklyEntry {
+	start := time.Time{}
+	if x.StartTime != nil {
+		start = *x.StartTime
+	}
+	return WeeklyEntry{
+		ChatID:    x.ChatID,
+		Weekday:   ISOWeekday(x.Date),
+		StartTime: start,
+		EndTime:   x.EndTime,
+		Title:     x.Title,
+		UserID:    x.UserID,
+		OwnerName: x.OwnerName,
+	}
+}
+
+type ScheduleExceptionsRepo interface {
+	Add(ctx context.Context, exs ...ScheduleException) error
+	Between(ctx context.Context, chatID int64, from, to time.Time) ([]ScheduleException, error)
+	Delete(ctx context.Context, chatID int64, userID *int64, id int64) (bool, error)
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type scheduleExceptionsPG struct{ db *pgxpool.Pool }
+
+func (s *Storage) Exceptions() ScheduleExceptionsRepo { return &scheduleExceptionsPG{s.pool} }
+
+// Add сохраняет исключения одной транзакцией: перенос — это отмена и
+// разовое занятие, которые не должны разойтись.
+func (r *scheduleExceptionsPG) Add(ctx context.Context, exs ...ScheduleException) error {
+	defer observe(ctx, "exceptions.Add", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	const ins = `
+INSERT INTO schedule_exceptions (chat_id, user_id, owner_name, on_date, kind, start_time, end_time, title)
+VALUES ($1,$2,NULLIF($3,''),$4,$5,$6,$7,$8)`
+	for _, x := range exs {
+		var start any
+		if x.StartTime != nil {
+			start = x.StartTime.Format("15:04:05")
+		}
+		if _, err := tx.Exec(ctx, ins, x.ChatID, x.UserID, x.OwnerName, x.Date, x.Kind,
+			start, nilOrTime(x.EndTime), x.Title); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Between возвращает исключения чата с датами from..to включительно.
+func (r *scheduleExceptionsPG) Between(ctx context.Context, chatID int64, from, to time.Time) ([]ScheduleException, error) {
+	defer observe(ctx, "exceptions.Between", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT id, chat_id, user_id, COALESCE(owner_name, ''), on_date, kind, start_time, end_time, title
+FROM schedule_exceptions
+WHERE chat_id=$1 AND on_date BETWEEN $2 AND $3
+ORDER BY on_date, start_time NULLS FIRST, id`
+	rows, err := r.db.Query(ctx, q, chatID, dateOf(from), dateOf(to))
+	if err != nil {
+		return nil, err
+	}
+	return scanExceptions(rows)
+}
+
+func scanExceptions(rows pgx.Rows) ([]ScheduleException, error) {
+	defer rows.Close()
+	var out []ScheduleException
+	for rows.Next() {
+		var x ScheduleException
+		if err := rows.Scan(&x.ID, &x.ChatID, &x.UserID, &x.OwnerName, &x.Date, &x.Kind,
+			&x.StartTime, &x.EndTime, &x.Title); err != nil {
+			return nil, err
+		}
+		out = append(out, x)
+	}
+	return out, rows.Err()
+}
+
+// Delete удаляет исключение владельца userID.
+func (r *scheduleExceptionsPG) Delete(ctx context.Context, chatID int64, userID *int64, id int64) (bool, error) {
+	defer observe(ctx, "exceptions.Delete", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tag, err := r.db.Exec(ctx, `
+DELETE FROM schedule_exceptions
+WHERE id=$1 AND chat_id=$2 AND user_id IS NOT DISTINCT FROM $3`, id, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PurgeBefore удаляет исключения всех чатов с датами раньше before: на
+// прошедшие дни они уже ни на что не влияют.
+func (r *scheduleExceptionsPG) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer observe(ctx, "exceptions.PurgeBefore", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	tag, err := r.db.Exec(ctx, `DELETE FROM schedule_exceptions WHERE on_date < $1`, dateOf(before))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// forgottenTTL — сколько помнить в памяти об удалённом чате: этого с
+// запасом хватает, чтобы уведомитель отбросил job'ы, выбранные до удаления.
+const forgottenTTL = time.Hour
+
+type ForgetRepo interface {
+	ForgetChat(ctx context.Context, chatID int64, kind string) (map[string]int64, error)
+}
+
+type forgetPG struct {
+	db *pgxpool.Pool
+	s  *Storage
+}
+
+func (s *Storage) Forget() ForgetRepo { return &forgetPG{s.pool, s} }
+
+// ForgetChat удаляет все данные чата одной транзакцией: job'ы его
+// напоминаний и строки всех таблиц схемы с колонкой chat_id, включая
+// добавленные будущими миграциями. Для личного чата (kind "private")
+// удаляется и запись known_users — chat_id там совпадает с user_id.
+// В forget_audit пишется обезличенная запись: тип чата и число строк по
+// таблицам. Возвращает те же числа.
+func (r *forgetPG) ForgetChat(ctx context.Context, chatID int64, kind string) (map[string]int64, error) {
+	defer observe(ctx, "forget.ForgetChat", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	deleted := map[string]int64{}
+	// у reminder_jobs нет chat_id — сначала job'ы через напоминания чата
+	tag, err := tx.Exec(ctx, `
+DELETE FROM reminder_jobs j USING reminders r
+WHERE j.reminder_id = r.id AND r.chat_id=$1`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	deleted["reminder_jobs"] = tag.RowsAffected()
+
+	rows, err := tx.Query(ctx, `
+SELECT c.table_name
+FROM information_schema.columns c
+JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+WHERE c.table_schema = current_schema() AND c.column_name = 'chat_id' AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_name`)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		tag, err := tx.Exec(ctx, `DELETE FROM `+pgx.Identifier{t}.Sanitize()+` WHERE chat_id=$1`, chatID)
+		if err != nil {
+			return nil, err
+		}
+		deleted[t] = tag.RowsAffected()
+	}
+	if kind == "private" {
+		tag, err := tx.Exec(ctx, `DELETE FROM known_users WHERE user_id=$1`, chatID)
+		if err != nil {
+			return nil, err
+		}
+		deleted["known_users"] = tag.RowsAffected()
+	}
+
+	counts, err := json.Marshal(deleted)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO forget_audit (chat_kind, deleted_rows) VALUES ($1,$2)`, kind, counts); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	r.s.markForgotten(chatID)
+	return deleted, nil
+}
+
+func (s *Storage) markForgotten(chatID int64) {
+	s.forgottenMu.Lock()
+	defer s.forgottenMu.Unlock()
+	now := time.Now()
+	for id, at := range s.forgotten {
+		if now.Sub(at) > forgottenTTL {
+			delete(s.forgotten, id)
+		}
+	}
+	s.forgotten[chatID] = now
+	s.signalJobs()
+}
+
+// ForgottenAfter сообщает, что данные чата удалены через /forget позже t.
+// Уведомитель так отбрасывает job'ы, выбранные из базы до удаления.
+func (s *Storage) ForgottenAfter(chatID int64, t time.Time) bool {
+	s.forgottenMu.Lock()
+	defer s.forgottenMu.Unlock()
+	at, ok := s.forgotten[chatID]
+	return ok && at.After(t)
+}
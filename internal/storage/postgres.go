@@ -1,17 +1,26 @@
 package storage
 
 import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Storage struct {
-	pool *pgxpool.Pool
+	pool        *pgxpool.Pool
+	jobsChanged chan struct{}
+	// forgotten — чаты, удалённые через /forget, и время удаления.
+	forgotten   map[int64]time.Time
+	forgottenMu sync.Mutex
 }
 
 func New(ctx context.Context, dsn string) (*Storage, error) {
@@ -23,11 +32,33 @@ func New(ctx context.Context, dsn string) (*Storage, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Storage{pool: pool}, nil
+	return &Storage{pool: pool, jobsChanged: make(chan struct{}, 1), forgotten: map[int64]time.Time{}}, nil
 }
 
 func (s *Storage) Close() { s.pool.Close() }
 
+// observe пишет длительность вызова репозитория в метрики и в debug-лог
+// с атрибутами апдейта из ctx.
+func observe(ctx context.Context, method string, start time.Time) {
+	d := time.Since(start)
+	metrics.DBQueryDuration.WithLabelValues(method).Observe(d.Seconds())
+	logging.FromContext(ctx).Debug("db call", "method", method, "duration_ms", d.Milliseconds())
+}
+
+// PoolStat — состояние пула соединений для /healthz.
+func (s *Storage) PoolStat() *pgxpool.Stat { return s.pool.Stat() }
+
+// JobsChanged сигналит, что в reminder_jobs появилась новая или сдвинутая задача.
+// Канал с буфером 1: несколько изменений подряд схлопываются в одно пробуждение.
+func (s *Storage) JobsChanged() <-chan struct{} { return s.jobsChanged }
+
+func (s *Storage) signalJobs() {
+	select {
+	case s.jobsChanged <- struct{}{}:
+	default:
+	}
+}
+
 func (s *Storage) Now(ctx context.Context) (time.Time, error) {
 	var t time.Time
 	err := s.pool.QueryRow(ctx, `SELECT now()`).Scan(&t)
@@ -39,18 +70,43 @@ type ChatSettings struct {
 	TimeZone        string
 	LocaleLanguage  string
 	DailyReportTime *time.Time
+	QuietFrom       *time.Time
+	QuietTo         *time.Time
+	QuietMode       string
+	// Holidays — как учитывать производственный календарь: HolidaysOff,
+	// HolidaysTimetable или HolidaysAll.
+	Holidays string
 }
 
+const (
+	QuietDelay  = "delay"
+	QuietSilent = "silent"
+)
+
+const (
+	HolidaysOff       = "off"
+	HolidaysTimetable = "timetable"
+	HolidaysAll       = "all"
+)
+
 type ChatDigestSlot struct {
-	ChatID   int64
-	TimeZone string
-	Daily    time.Time
+	ChatID    int64
+	TimeZone  string
+	Daily     time.Time
+	QuietFrom *time.Time
+	QuietTo   *time.Time
+	Holidays  string
 }
 
 type ChatSettingsRepo interface {
 	Get(ctx context.Context, chatID int64) (ChatSettings, error)
 	UpsertTZ(ctx context.Context, chatID int64, tz string) error
 	UpsertDigest(ctx context.Context, chatID int64, t *time.Time) error
+	UpsertQuiet(ctx context.Context, chatID int64, from, to *time.Time, mode string) error
+	UpsertHolidays(ctx context.Context, chatID int64, mode string) error
+	Patch(ctx context.Context, chatID int64, p SettingsPatch) error
+	CalendarToken(ctx context.Context, chatID int64, reset bool) (string, error)
+	ByCalendarToken(ctx context.Context, token string) (ChatSettings, bool, error)
 	ChatsToDigestNow(ctx context.Context) ([]ChatDigestSlot, error)
 }
 
@@ -59,21 +115,25 @@ type chatSettingsPG struct{ db *pgxpool.Pool }
 func (s *Storage) ChatSettings() ChatSettingsRepo { return &chatSettingsPG{s.pool} }
 
 func (r *chatSettingsPG) Get(ctx context.Context, chatID int64) (ChatSettings, error) {
+	defer observe(ctx, "chatSettings.Get", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	const q = `SELECT chat_id, time_zone, locale_language, daily_report_time
+	const q = `SELECT chat_id, time_zone, locale_language, daily_report_time,
+	                  quiet_from, quiet_to, quiet_mode, holidays
 	           FROM chat_settings WHERE chat_id=$1`
 	var cs ChatSettings
-	err := r.db.QueryRow(ctx, q, chatID).Scan(&cs.ChatID, &cs.TimeZone, &cs.LocaleLanguage, &cs.DailyReportTime)
+	err := r.db.QueryRow(ctx, q, chatID).Scan(&cs.ChatID, &cs.TimeZone, &cs.LocaleLanguage, &cs.DailyReportTime,
+		&cs.QuietFrom, &cs.QuietTo, &cs.QuietMode, &cs.Holidays)
 	return cs, err
 }
 
 func (r *chatSettingsPG) ChatsToDigestNow(ctx context.Context) ([]ChatDigestSlot, error) {
+	defer observe(ctx, "chatSettings.ChatsToDigestNow", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	const q = `
-        SELECT chat_id, time_zone, daily_report_time
+        SELECT chat_id, time_zone, daily_report_time, quiet_from, quiet_to, holidays
         FROM chat_settings
         WHERE daily_report_time IS NOT NULL
     `
@@ -87,7 +147,7 @@ func (r *chatSettingsPG) ChatsToDigestNow(ctx context.Context) ([]ChatDigestSlot
 	var out []ChatDigestSlot
 	for rows.Next() {
 		var s ChatDigestSlot
-		if err := rows.Scan(&s.ChatID, &s.TimeZone, &s.Daily); err != nil {
+		if err := rows.Scan(&s.ChatID, &s.TimeZone, &s.Daily, &s.QuietFrom, &s.QuietTo, &s.Holidays); err != nil {
 			return nil, err
 		}
 		out = append(out, s)
@@ -96,6 +156,7 @@ func (r *chatSettingsPG) ChatsToDigestNow(ctx context.Context) ([]ChatDigestSlot
 }
 
 func (r *chatSettingsPG) UpsertTZ(ctx context.Context, chatID int64, tz string) error {
+	defer observe(ctx, "chatSettings.UpsertTZ", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
@@ -107,6 +168,7 @@ ON CONFLICT (chat_id) DO UPDATE SET time_zone=EXCLUDED.time_zone`
 }
 
 func (r *chatSettingsPG) UpsertDigest(ctx context.Context, chatID int64, t *time.Time) error {
+	defer observe(ctx, "chatSettings.UpsertDigest", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
@@ -117,25 +179,170 @@ ON CONFLICT (chat_id) DO UPDATE SET daily_report_time=EXCLUDED.daily_report_time
 	return err
 }
 
+func (r *chatSettingsPG) UpsertQuiet(ctx context.Context, chatID int64, from, to *time.Time, mode string) error {
+	defer observe(ctx, "chatSettings.UpsertQuiet", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+INSERT INTO chat_settings (chat_id, quiet_from, quiet_to, quiet_mode)
+VALUES ($1,$2,$3,$4)
+ON CONFLICT (chat_id) DO UPDATE SET quiet_from=EXCLUDED.quiet_from, quiet_to=EXCLUDED.quiet_to, quiet_mode=EXCLUDED.quiet_mode`
+	_, err := r.db.Exec(ctx, q, chatID, from, to, mode)
+	return err
+}
+
+func (r *chatSettingsPG) UpsertHolidays(ctx context.Context, chatID int64, mode string) error {
+	defer observe(ctx, "chatSettings.UpsertHolidays", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+INSERT INTO chat_settings (chat_id, holidays)
+VALUES ($1,$2)
+ON CONFLICT (chat_id) DO UPDATE SET holidays=EXCLUDED.holidays`
+	_, err := r.db.Exec(ctx, q, chatID, mode)
+	return err
+}
+
+// SettingsPatch — частичное изменение настроек чата: nil и false — поле не
+// меняется. Daily и QuietFrom/QuietTo могут быть nil при SetDaily/SetQuiet —
+// это выключение отчёта или тихих часов.
+type SettingsPatch struct {
+	TimeZone  *string
+	SetDaily  bool
+	Daily     *time.Time
+	SetQuiet  bool
+	QuietFrom *time.Time
+	QuietTo   *time.Time
+	QuietMode *string
+}
+
+// Patch применяет все поля p одной транзакцией: либо меняется всё, либо ничего.
+func (r *chatSettingsPG) Patch(ctx context.Context, chatID int64, p SettingsPatch) error {
+	defer observe(ctx, "chatSettings.Patch", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `INSERT INTO chat_settings (chat_id) VALUES ($1) ON CONFLICT (chat_id) DO NOTHING`, chatID); err != nil {
+		return err
+	}
+	const q = `
+UPDATE chat_settings SET
+    time_zone         = COALESCE($2, time_zone),
+    daily_report_time = CASE WHEN $3 THEN $4 ELSE daily_report_time END,
+    quiet_from        = CASE WHEN $5 THEN $6 ELSE quiet_from END,
+    quiet_to          = CASE WHEN $5 THEN $7 ELSE quiet_to END,
+    quiet_mode        = COALESCE($8, quiet_mode)
+WHERE chat_id=$1`
+	if _, err := tx.Exec(ctx, q, chatID, p.TimeZone, p.SetDaily, p.Daily, p.SetQuiet, p.QuietFrom, p.QuietTo, p.QuietMode); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CalendarToken возвращает секрет ссылки на календарь чата, создавая его при
+// первом обращении. reset выпускает новый секрет, старая ссылка перестаёт работать.
+func (r *chatSettingsPG) CalendarToken(ctx context.Context, chatID int64, reset bool) (string, error) {
+	defer observe(ctx, "chatSettings.CalendarToken", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if !reset {
+		var token *string
+		err := r.db.QueryRow(ctx, `SELECT calendar_token FROM chat_settings WHERE chat_id=$1`, chatID).Scan(&token)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+		if token != nil {
+			return *token, nil
+		}
+	}
+	token, err := NewSecret()
+	if err != nil {
+		return "", err
+	}
+	const q = `
+INSERT INTO chat_settings (chat_id, calendar_token)
+VALUES ($1,$2)
+ON CONFLICT (chat_id) DO UPDATE SET calendar_token=EXCLUDED.calendar_token`
+	_, err = r.db.Exec(ctx, q, chatID, token)
+	return token, err
+}
+
+func (r *chatSettingsPG) ByCalendarToken(ctx context.Context, token string) (ChatSettings, bool, error) {
+	defer observe(ctx, "chatSettings.ByCalendarToken", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var chatID int64
+	err := r.db.QueryRow(ctx, `SELECT chat_id FROM chat_settings WHERE calendar_token=$1`, token).Scan(&chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ChatSettings{}, false, nil
+	}
+	if err != nil {
+		return ChatSettings{}, false, err
+	}
+	cs, err := r.Get(ctx, chatID)
+	return cs, err == nil, err
+}
+
 type Reminder struct {
 	ID           int64
 	ChatID       int64
 	Message      string
 	EventTime    *time.Time
 	ReminderTime int
-	ReminderRule *string
-	NextReport   *time.Time
-	CreatedAt    time.Time
+	// ReminderOffsets — все смещения напоминаний в минутах до события, по
+	// убыванию; ReminderTime хранит первое (самое раннее) из них.
+	ReminderOffsets []int
+	ReminderRule    *string
+	NextReport      *time.Time
+	CreatedAt       time.Time
+	// Persistent — повторять напоминание каждые NagInterval минут (не больше
+	// NagMax раз), пока пользователь не нажмёт «Готово».
+	Persistent     bool
+	NagInterval    int
+	NagMax         int
+	AcknowledgedAt *time.Time
+	// UserID и AuthorName — кто создал напоминание (в группах его упоминают
+	// при срабатывании). Broadcast — «напомни всем», без упоминания автора.
+	UserID     *int64
+	AuthorName string
+	Broadcast  bool
+	// AssigneeID — участник группы, которому назначено напоминание.
+	AssigneeID   *int64
+	AssigneeName string
+	// FiredAt — последняя отправка. ArchivedAt — разовое напоминание
+	// отработало и лежит в истории. SnoozedCount — сколько раз откладывали.
+	FiredAt      *time.Time
+	ArchivedAt   *time.Time
+	SnoozedCount int
 }
 
+// Значения настойчивого режима по умолчанию: повтор каждые 10 минут, до 6 раз.
+const (
+	DefaultNagInterval = 10
+	DefaultNagMax      = 6
+)
+
 type RemindersRepo interface {
 	Create(ctx context.Context, r *Reminder) (int64, error)
-	UpdateDue(ctx context.Context, id int64, eventTime time.Time, leadMin int) error
+	UpdateDue(ctx context.Context, id int64, eventTime time.Time, offsets []int) error
 	UpdateNextReport(ctx context.Context, id int64, t *time.Time) error
 	GetUpcoming(ctx context.Context, chatID int64, from time.Time, to *time.Time, limit int) ([]Reminder, error)
-	AddReminder(ctx context.Context, chatID int64, title string, eventTime time.Time, leadMinutes int) (int64, error)
-	AddRecurring(ctx context.Context, chatID int64, title string, leadMinutes int, rule string, next time.Time) (int64, error)
-	DeleteIfNoPending(ctx context.Context, reminderID int64) error
+	Get(ctx context.Context, chatID, id int64) (Reminder, error)
+	ListAll(ctx context.Context, chatID int64) ([]Reminder, error)
+	Update(ctx context.Context, m *Reminder) error
+	Delete(ctx context.Context, chatID, id int64) (bool, error)
+	AddReminder(ctx context.Context, chatID int64, title string, eventTime time.Time, offsets []int) (int64, error)
+	AddRecurring(ctx context.Context, chatID int64, title string, offsets []int, rule string, next time.Time) (int64, error)
+	ArchiveIfNoPending(ctx context.Context, reminderID int64) error
+	Acknowledge(ctx context.Context, chatID, reminderID, userID int64) (bool, error)
+	ClearAck(ctx context.Context, reminderID int64) error
+	History(ctx context.Context, chatID int64, from, to time.Time, limit int) ([]Reminder, error)
+	PurgeArchived(ctx context.Context, before time.Time) (int64, error)
 }
 
 type remindersPG struct{ db *pgxpool.Pool }
@@ -143,65 +350,169 @@ type remindersPG struct{ db *pgxpool.Pool }
 func (s *Storage) Reminders() RemindersRepo { return &remindersPG{s.pool} }
 
 func (r *remindersPG) Create(ctx context.Context, m *Reminder) (int64, error) {
+	defer observe(ctx, "reminders.Create", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
-INSERT INTO reminders (chat_id, message, event_time, reminder_time, reminder_rule, next_report)
-VALUES ($1,$2,$3,$4,$5,$6)
+INSERT INTO reminders (chat_id, message, event_time, reminder_time, reminder_offsets, reminder_rule, next_report,
+                       persistent, nag_interval, nag_max, user_id, author_name, broadcast,
+                       assignee_id, assignee_name)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,NULLIF($12,''),$13,$14,NULLIF($15,''))
 RETURNING id`
+	offsets := m.ReminderOffsets
+	if len(offsets) == 0 {
+		offsets = []int{m.ReminderTime}
+	}
 	var id int64
-	err := r.db.QueryRow(ctx, q, m.ChatID, m.Message, m.EventTime, m.ReminderTime, m.ReminderRule, m.NextReport).Scan(&id)
+	err := r.db.QueryRow(ctx, q, m.ChatID, m.Message, m.EventTime, offsets[0], offsets, m.ReminderRule, m.NextReport,
+		m.Persistent, m.NagInterval, m.NagMax, m.UserID, m.AuthorName, m.Broadcast,
+		m.AssigneeID, m.AssigneeName).Scan(&id)
 	return id, err
 }
-func (r *remindersPG) DeleteIfNoPending(ctx context.Context, reminderID int64) error {
+
+// ArchiveIfNoPending переносит отработавшее разовое напоминание в историю,
+// если по нему не осталось неотправленных job'ов.
+func (r *remindersPG) ArchiveIfNoPending(ctx context.Context, reminderID int64) error {
+	defer observe(ctx, "reminders.ArchiveIfNoPending", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// удаляем reminder, только если по нему НЕТ НЕотправленных job
 	const q = `
-DELETE FROM reminders rem
+UPDATE reminders rem SET archived_at = now()
 WHERE rem.id = $1
+  AND rem.archived_at IS NULL
   AND rem.reminder_rule IS NULL  -- только разовые
   AND NOT EXISTS (
         SELECT 1 FROM reminder_jobs j
-        WHERE j.reminder_id = rem.id AND j.sent_at IS NULL
+        WHERE j.reminder_id = rem.id AND j.sent_at IS NULL AND j.skipped_at IS NULL
   )`
 	_, err := r.db.Exec(ctx, q, reminderID)
 	return err
 }
 
-func (r *remindersPG) UpdateDue(ctx context.Context, id int64, eventTime time.Time, leadMin int) error {
+// History возвращает архивные напоминания чата, отработавшие в [from, to),
+// от новых к старым.
+func (r *remindersPG) History(ctx context.Context, chatID int64, from, to time.Time, limit int) ([]Reminder, error) {
+	defer observe(ctx, "reminders.History", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	const q = `UPDATE reminders SET event_time=$2, reminder_time=$3 WHERE id=$1`
-	_, err := r.db.Exec(ctx, q, id, eventTime, leadMin)
+	q := `
+SELECT ` + reminderColumns + `
+FROM reminders
+WHERE chat_id=$1 AND archived_at IS NOT NULL
+  AND COALESCE(fired_at, archived_at) >= $2 AND COALESCE(fired_at, archived_at) < $3
+ORDER BY COALESCE(fired_at, archived_at) DESC
+LIMIT $4`
+	rows, err := r.db.Query(ctx, q, chatID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanReminders(rows)
+}
+
+// PurgeArchived удаляет из истории напоминания, ушедшие в архив раньше
+// before, вместе с их job'ами.
+func (r *remindersPG) PurgeArchived(ctx context.Context, before time.Time) (int64, error) {
+	defer observe(ctx, "reminders.PurgeArchived", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	const delJobs = `
+DELETE FROM reminder_jobs j USING reminders r
+WHERE j.reminder_id = r.id AND r.archived_at < $1`
+	if _, err := tx.Exec(ctx, delJobs, before); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM reminders WHERE archived_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// Acknowledge отмечает напоминание выполненным и снимает ещё не отправленные
+// повторы настойчивого режима. Назначенное напоминание может закрыть
+// исполнитель (в том числе из лички) или автор, остальные — любой участник
+// чата напоминания.
+func (r *remindersPG) Acknowledge(ctx context.Context, chatID, reminderID, userID int64) (bool, error) {
+	defer observe(ctx, "reminders.Acknowledge", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	const ack = `
+UPDATE reminders SET acknowledged_at=now()
+WHERE id=$1
+  AND (assignee_id = $3 OR (chat_id = $2 AND (assignee_id IS NULL OR user_id = $3)))`
+	tag, err := tx.Exec(ctx, ack, reminderID, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	const skipNags = `
+UPDATE reminder_jobs SET skipped_at=now(), last_error='acknowledged'
+WHERE reminder_id=$1 AND nag_seq > 0 AND sent_at IS NULL AND skipped_at IS NULL`
+	if _, err := tx.Exec(ctx, skipNags, reminderID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *remindersPG) ClearAck(ctx context.Context, reminderID int64) error {
+	defer observe(ctx, "reminders.ClearAck", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := r.db.Exec(ctx, `UPDATE reminders SET acknowledged_at=NULL WHERE id=$1`, reminderID)
+	return err
+}
+
+func (r *remindersPG) UpdateDue(ctx context.Context, id int64, eventTime time.Time, offsets []int) error {
+	defer observe(ctx, "reminders.UpdateDue", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `UPDATE reminders SET event_time=$2, reminder_time=$3, reminder_offsets=$4 WHERE id=$1`
+	_, err := r.db.Exec(ctx, q, id, eventTime, offsets[0], offsets)
 	return err
 }
 
 func (r *remindersPG) UpdateNextReport(ctx context.Context, id int64, t *time.Time) error {
+	defer observe(ctx, "reminders.UpdateNextReport", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `UPDATE reminders SET next_report=$2 WHERE id=$1`
 	_, err := r.db.Exec(ctx, q, id, t)
 	return err
 }
-func (r *remindersPG) AddReminder(ctx context.Context, chatID int64, title string, eventTime time.Time, leadMinutes int) (int64, error) {
+func (r *remindersPG) AddReminder(ctx context.Context, chatID int64, title string, eventTime time.Time, offsets []int) (int64, error) {
+	defer observe(ctx, "reminders.AddReminder", time.Now())
 	const q = `
-        INSERT INTO reminders (chat_id, message, event_time, reminder_time)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO reminders (chat_id, message, event_time, reminder_time, reminder_offsets)
+        VALUES ($1, $2, $3, $4, $5)
         RETURNING id`
 	var id int64
-	err := r.db.QueryRow(ctx, q, chatID, title, eventTime, leadMinutes).Scan(&id)
+	err := r.db.QueryRow(ctx, q, chatID, title, eventTime, offsets[0], offsets).Scan(&id)
 	return id, err
 }
 
-func (r *remindersPG) AddRecurring(ctx context.Context, chatID int64, title string, leadMinutes int, rule string, next time.Time) (int64, error) {
+func (r *remindersPG) AddRecurring(ctx context.Context, chatID int64, title string, offsets []int, rule string, next time.Time) (int64, error) {
+	defer observe(ctx, "reminders.AddRecurring", time.Now())
 	const q = `
-        INSERT INTO reminders (chat_id, message, reminder_time, reminder_rule, next_report)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO reminders (chat_id, message, reminder_time, reminder_offsets, reminder_rule, next_report)
+        VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING id`
 	var id int64
-	err := r.db.QueryRow(ctx, q, chatID, title, leadMinutes, rule, next).Scan(&id)
+	err := r.db.QueryRow(ctx, q, chatID, title, offsets[0], offsets, rule, next).Scan(&id)
 	return id, err
 }
 
@@ -212,13 +523,14 @@ func (r *remindersPG) GetUpcoming(
 	to *time.Time,
 	limit int,
 ) ([]Reminder, error) {
+	defer observe(ctx, "reminders.GetUpcoming", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	base := `
-SELECT id, chat_id, message, event_time, reminder_time, reminder_rule, next_report, created_at
+SELECT ` + reminderColumns + `
 FROM reminders
-WHERE chat_id = $1
+WHERE chat_id = $1 AND archived_at IS NULL
   AND (
         (event_time  IS NOT NULL AND event_time  >= $2) OR
         (next_report IS NOT NULL AND next_report >= $2)
@@ -231,19 +543,36 @@ WHERE chat_id = $1
 		args = append(args, *to)
 	}
 
-	base += ` ORDER BY COALESCE(next_report, event_time) ASC LIMIT $4`
 	args = append(args, limit)
+	base += fmt.Sprintf(` ORDER BY COALESCE(next_report, event_time) ASC LIMIT $%d`, len(args))
 
 	rows, err := r.db.Query(ctx, base, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanReminders(rows)
+}
+
+const reminderColumns = `id, chat_id, message, event_time, reminder_time,
+       COALESCE(reminder_offsets, ARRAY[reminder_time]), reminder_rule, next_report, created_at,
+       persistent, COALESCE(nag_interval, 0), COALESCE(nag_max, 0), acknowledged_at,
+       user_id, COALESCE(author_name, ''), broadcast, assignee_id, COALESCE(assignee_name, ''),
+       fired_at, archived_at, snoozed_count`
+
+func scanReminder(row pgx.Row, m *Reminder) error {
+	return row.Scan(&m.ID, &m.ChatID, &m.Message, &m.EventTime, &m.ReminderTime,
+		&m.ReminderOffsets, &m.ReminderRule, &m.NextReport, &m.CreatedAt,
+		&m.Persistent, &m.NagInterval, &m.NagMax, &m.AcknowledgedAt,
+		&m.UserID, &m.AuthorName, &m.Broadcast, &m.AssigneeID, &m.AssigneeName,
+		&m.FiredAt, &m.ArchivedAt, &m.SnoozedCount)
+}
 
+func scanReminders(rows pgx.Rows) ([]Reminder, error) {
+	defer rows.Close()
 	var out []Reminder
 	for rows.Next() {
 		var m Reminder
-		if err := rows.Scan(&m.ID, &m.ChatID, &m.Message, &m.EventTime, &m.ReminderTime, &m.ReminderRule, &m.NextReport, &m.CreatedAt); err != nil {
+		if err := scanReminder(rows, &m); err != nil {
 			return nil, err
 		}
 		out = append(out, m)
@@ -251,83 +580,479 @@ WHERE chat_id = $1
 	return out, rows.Err()
 }
 
+func (r *remindersPG) Get(ctx context.Context, chatID, id int64) (Reminder, error) {
+	defer observe(ctx, "reminders.Get", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	q := `SELECT ` + reminderColumns + ` FROM reminders WHERE id=$1 AND chat_id=$2 AND archived_at IS NULL`
+	var m Reminder
+	err := scanReminder(r.db.QueryRow(ctx, q, id, chatID), &m)
+	return m, err
+}
+
+func (r *remindersPG) ListAll(ctx context.Context, chatID int64) ([]Reminder, error) {
+	defer observe(ctx, "reminders.ListAll", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	q := `SELECT ` + reminderColumns + ` FROM reminders WHERE chat_id=$1 AND archived_at IS NULL ORDER BY id`
+	rows, err := r.db.Query(ctx, q, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return scanReminders(rows)
+}
+
+// Update перезаписывает редактируемые поля напоминания.
+func (r *remindersPG) Update(ctx context.Context, m *Reminder) error {
+	defer observe(ctx, "reminders.Update", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+UPDATE reminders
+SET message=$3, event_time=$4, reminder_time=$5, reminder_offsets=$6, reminder_rule=$7, next_report=$8,
+    persistent=$9, nag_interval=$10, nag_max=$11
+WHERE id=$1 AND chat_id=$2`
+	_, err := r.db.Exec(ctx, q, m.ID, m.ChatID, m.Message, m.EventTime, m.ReminderOffsets[0], m.ReminderOffsets,
+		m.ReminderRule, m.NextReport, m.Persistent, m.NagInterval, m.NagMax)
+	return err
+}
+
+// Delete удаляет напоминание вместе со всеми его job'ами.
+func (r *remindersPG) Delete(ctx context.Context, chatID, id int64) (bool, error) {
+	defer observe(ctx, "reminders.Delete", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	const delJobs = `
+DELETE FROM reminder_jobs j USING reminders r
+WHERE j.reminder_id = r.id AND r.id=$1 AND r.chat_id=$2`
+	if _, err := tx.Exec(ctx, delJobs, id, chatID); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM reminders WHERE id=$1 AND chat_id=$2`, id, chatID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, tx.Commit(ctx)
+}
+
 type Job struct {
-	ID           int64
-	ReminderID   int64
-	ReportTime   time.Time
-	SentAt       *time.Time
-	ChatID       int64
-	Message      string
-	ReminderTime int
-	ReminderRule *string
+	ID              int64
+	ReminderID      int64
+	ReportTime      time.Time
+	SentAt          *time.Time
+	Attempts        int
+	LastError       *string
+	NextAttemptAt   *time.Time
+	FailedAt        *time.Time
+	ChatID          int64
+	Message         string
+	ReminderTime    int
+	ReminderOffsets []int
+	ReminderRule    *string
+	EventTime       *time.Time
+	NextReport      *time.Time
+	// NagSeq > 0 — повтор настойчивого напоминания с этим номером.
+	NagSeq         int
+	Persistent     bool
+	NagInterval    int
+	NagMax         int
+	AcknowledgedAt *time.Time
+	UserID         *int64
+	AuthorName     string
+	Broadcast      bool
+	AssigneeID     *int64
+	AssigneeName   string
+	// AssigneeDM — исполнитель запускал бота, и напоминание можно прислать ему в личку.
+	AssigneeDM bool
+	// ClaimedAt — когда job захвачен для отправки; nil — ещё не отправлялся.
+	ClaimedAt *time.Time
+}
+
+// Occurrence — момент события, к которому относится job: event_time для
+// разовых напоминаний и текущий next_report для повторяющихся.
+func (j Job) Occurrence() *time.Time {
+	if j.EventTime != nil {
+		return j.EventTime
+	}
+	return j.NextReport
 }
 
 type JobsRepo interface {
 	Create(ctx context.Context, reminderID int64, reportTime time.Time) error
+	CreateForEvent(ctx context.Context, reminderID int64, event time.Time, offsets []int, now time.Time) error
+	CreateNag(ctx context.Context, reminderID int64, reportTime time.Time, seq int) error
+	PendingCount(ctx context.Context, reminderID int64) (int, error)
+	DeletePending(ctx context.Context, reminderID int64) error
 	Due(ctx context.Context, now time.Time, limit int) ([]Job, error)
 	MarkSent(ctx context.Context, jobID int64) error
-	Snooze(ctx context.Context, jobID int64, d time.Duration) error
+	MarkSkipped(ctx context.Context, jobID int64, reason string) error
+	Defer(ctx context.Context, jobID int64, until time.Time) error
+	RecordFailure(ctx context.Context, jobID int64, errMsg string, retryAt *time.Time) error
+	Failed(ctx context.Context, limit int) ([]Job, error)
+	Requeue(ctx context.Context, jobID int64) (bool, error)
+	Snooze(ctx context.Context, chatID, reminderID, userID int64, at time.Time) (bool, error)
+	NextDue(ctx context.Context) (*time.Time, error)
+	SkipNags(ctx context.Context, reminderID int64, reason string) error
+	Claim(ctx context.Context, jobID int64, confirmBy time.Time) (bool, error)
+	Release(ctx context.Context, jobID int64, at time.Time) error
+	Claimed(ctx context.Context, now time.Time, limit int) ([]Job, error)
 }
 
-type jobsPG struct{ db *pgxpool.Pool }
+type jobsPG struct {
+	db     *pgxpool.Pool
+	notify func()
+}
+
+func (s *Storage) Jobs() JobsRepo { return &jobsPG{s.pool, s.signalJobs} }
 
-func (s *Storage) Jobs() JobsRepo { return &jobsPG{s.pool} }
+const jobColumns = `j.id, j.reminder_id, j.report_time, j.sent_at,
+       j.attempts, j.last_error, j.next_attempt_at, j.failed_at,
+       r.chat_id, r.message, r.reminder_time,
+       COALESCE(r.reminder_offsets, ARRAY[r.reminder_time]), r.reminder_rule,
+       r.event_time, r.next_report,
+       j.nag_seq, r.persistent, COALESCE(r.nag_interval, 0), COALESCE(r.nag_max, 0), r.acknowledged_at,
+       r.user_id, COALESCE(r.author_name, ''), r.broadcast, r.assignee_id, COALESCE(r.assignee_name, ''),
+       COALESCE((SELECT u.has_private_chat FROM known_users u WHERE u.user_id = r.assignee_id), false),
+       j.claimed_at`
+
+func scanJobs(rows pgx.Rows) ([]Job, error) {
+	defer rows.Close()
+	var out []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.ID, &j.ReminderID, &j.ReportTime, &j.SentAt,
+			&j.Attempts, &j.LastError, &j.NextAttemptAt, &j.FailedAt,
+			&j.ChatID, &j.Message, &j.ReminderTime, &j.ReminderOffsets, &j.ReminderRule,
+			&j.EventTime, &j.NextReport,
+			&j.NagSeq, &j.Persistent, &j.NagInterval, &j.NagMax, &j.AcknowledgedAt,
+			&j.UserID, &j.AuthorName, &j.Broadcast, &j.AssigneeID, &j.AssigneeName, &j.AssigneeDM,
+			&j.ClaimedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
 
 func (r *jobsPG) Create(ctx context.Context, reminderID int64, reportTime time.Time) error {
+	defer observe(ctx, "jobs.Create", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
 INSERT INTO reminder_jobs (reminder_id, report_time)
 VALUES ($1,$2)
 ON CONFLICT (reminder_id, report_time) DO NOTHING`
-	_, err := r.db.Exec(ctx, q, reminderID, reportTime)
+	tag, err := r.db.Exec(ctx, q, reminderID, reportTime)
+	if err == nil && tag.RowsAffected() > 0 {
+		r.notify()
+	}
+	return err
+}
+
+// CreateForEvent создаёт по job'у на каждое смещение до события. Смещения,
+// время которых уже прошло, схлопываются в один job на now.
+func (r *jobsPG) CreateForEvent(ctx context.Context, reminderID int64, event time.Time, offsets []int, now time.Time) error {
+	defer observe(ctx, "jobs.CreateForEvent", time.Now())
+	late := false
+	for _, off := range offsets {
+		fire := event.Add(-time.Duration(off) * time.Minute)
+		if fire.Before(now) {
+			late = true
+			continue
+		}
+		if err := r.Create(ctx, reminderID, fire); err != nil {
+			return err
+		}
+	}
+	if late {
+		return r.Create(ctx, reminderID, now)
+	}
+	return nil
+}
+
+func (r *jobsPG) CreateNag(ctx context.Context, reminderID int64, reportTime time.Time, seq int) error {
+	defer observe(ctx, "jobs.CreateNag", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+INSERT INTO reminder_jobs (reminder_id, report_time, nag_seq)
+VALUES ($1,$2,$3)
+ON CONFLICT (reminder_id, report_time) DO NOTHING`
+	tag, err := r.db.Exec(ctx, q, reminderID, reportTime, seq)
+	if err == nil && tag.RowsAffected() > 0 {
+		r.notify()
+	}
+	return err
+}
+
+// PendingCount — сколько основных (не повторных) job'ов по напоминанию ещё
+// ждут отправки.
+func (r *jobsPG) PendingCount(ctx context.Context, reminderID int64) (int, error) {
+	defer observe(ctx, "jobs.PendingCount", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT count(*) FROM reminder_jobs
+WHERE reminder_id=$1 AND nag_seq=0 AND sent_at IS NULL AND failed_at IS NULL AND skipped_at IS NULL`
+	var n int
+	err := r.db.QueryRow(ctx, q, reminderID).Scan(&n)
+	return n, err
+}
+
+// DeletePending удаляет ещё не отправленные job'ы напоминания, например
+// перед перепланированием после изменения времени.
+func (r *jobsPG) DeletePending(ctx context.Context, reminderID int64) error {
+	defer observe(ctx, "jobs.DeletePending", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := r.db.Exec(ctx, `DELETE FROM reminder_jobs WHERE reminder_id=$1 AND sent_at IS NULL`, reminderID)
 	return err
 }
 
 func (r *jobsPG) Due(ctx context.Context, now time.Time, limit int) ([]Job, error) {
+	defer observe(ctx, "jobs.Due", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
-SELECT j.id, j.reminder_id, j.report_time, j.sent_at,
-       r.chat_id, r.message, r.reminder_time, r.reminder_rule
+SELECT ` + jobColumns + `
 FROM reminder_jobs j
 JOIN reminders r ON r.id=j.reminder_id
-WHERE j.sent_at IS NULL AND j.report_time <= $1
-ORDER BY j.report_time
+WHERE j.sent_at IS NULL AND j.failed_at IS NULL AND j.skipped_at IS NULL AND j.claimed_at IS NULL
+  AND COALESCE(j.next_attempt_at, j.report_time) <= $1
+ORDER BY COALESCE(j.next_attempt_at, j.report_time)
 LIMIT $2`
 	rows, err := r.db.Query(ctx, q, now, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var out []Job
-	for rows.Next() {
-		var j Job
-		if err := rows.Scan(&j.ID, &j.ReminderID, &j.ReportTime, &j.SentAt, &j.ChatID, &j.Message, &j.ReminderTime, &j.ReminderRule); err != nil {
-			return nil, err
-		}
-		out = append(out, j)
-	}
-	return out, rows.Err()
+	return scanJobs(rows)
 }
 
 func (r *jobsPG) MarkSent(ctx context.Context, jobID int64) error {
+	defer observe(ctx, "jobs.MarkSent", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	const q = `UPDATE reminder_jobs SET sent_at=now() WHERE id=$1 AND sent_at IS NULL`
+	const q = `
+WITH j AS (
+    UPDATE reminder_jobs SET sent_at=now() WHERE id=$1 AND sent_at IS NULL RETURNING reminder_id
+)
+UPDATE reminders SET fired_at=now() WHERE id IN (SELECT reminder_id FROM j)`
 	_, err := r.db.Exec(ctx, q, jobID)
 	return err
 }
 
-func (r *jobsPG) Snooze(ctx context.Context, jobID int64, d time.Duration) error {
+// MarkSkipped закрывает job без отправки, например если он безнадёжно опоздал.
+func (r *jobsPG) MarkSkipped(ctx context.Context, jobID int64, reason string) error {
+	defer observe(ctx, "jobs.MarkSkipped", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `UPDATE reminder_jobs SET skipped_at=now(), last_error=$2 WHERE id=$1 AND sent_at IS NULL`
+	_, err := r.db.Exec(ctx, q, jobID, reason)
+	return err
+}
+
+// Defer откладывает job до until, не засчитывая попытку (тихие часы).
+func (r *jobsPG) Defer(ctx context.Context, jobID int64, until time.Time) error {
+	defer observe(ctx, "jobs.Defer", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `UPDATE reminder_jobs SET next_attempt_at=$2 WHERE id=$1 AND sent_at IS NULL`
+	_, err := r.db.Exec(ctx, q, jobID, until)
+	if err == nil {
+		r.notify()
+	}
+	return err
+}
+
+// RecordFailure засчитывает неудачную попытку отправки. Если retryAt == nil,
+// попытки исчерпаны и job переходит в состояние failed (dead letter).
+func (r *jobsPG) RecordFailure(ctx context.Context, jobID int64, errMsg string, retryAt *time.Time) error {
+	defer observe(ctx, "jobs.RecordFailure", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	const q = `UPDATE reminder_jobs SET report_time = report_time + $2 WHERE id=$1 AND sent_at IS NULL`
-	_, err := r.db.Exec(ctx, q, jobID, d)
+	const q = `
+UPDATE reminder_jobs
+SET attempts        = attempts + 1,
+    last_error      = $2,
+    next_attempt_at = $3,
+    failed_at       = CASE WHEN $3::timestamptz IS NULL THEN now() END,
+    claimed_at      = NULL
+WHERE id=$1 AND sent_at IS NULL`
+	_, err := r.db.Exec(ctx, q, jobID, errMsg, retryAt)
 	return err
 }
 
+func (r *jobsPG) Failed(ctx context.Context, limit int) ([]Job, error) {
+	defer observe(ctx, "jobs.Failed", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT ` + jobColumns + `
+FROM reminder_jobs j
+JOIN reminders r ON r.id=j.reminder_id
+WHERE j.sent_at IS NULL AND j.failed_at IS NOT NULL
+ORDER BY j.failed_at DESC
+LIMIT $1`
+	rows, err := r.db.Query(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// Requeue возвращает упавший job в очередь: счётчик попыток сбрасывается,
+// отправка произойдёт при ближайшем проходе планировщика.
+func (r *jobsPG) Requeue(ctx context.Context, jobID int64) (bool, error) {
+	defer observe(ctx, "jobs.Requeue", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+UPDATE reminder_jobs
+SET attempts=0, last_error=NULL, next_attempt_at=NULL, failed_at=NULL, claimed_at=NULL
+WHERE id=$1 AND sent_at IS NULL AND failed_at IS NOT NULL`
+	tag, err := r.db.Exec(ctx, q, jobID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	r.notify()
+	return true, nil
+}
+
+// Snooze откладывает пришедшее разовое напоминание: ставит новую отправку на
+// at, возвращает напоминание из архива и увеличивает snoozed_count. Ещё не
+// ушедшие повторы настойчивого напоминания закрываются — цепочка начнётся
+// заново после отложенной отправки. Кто может откладывать — как в
+// Acknowledge. false — напоминание уже выполнено, повторяющееся или
+// назначено другому.
+func (r *jobsPG) Snooze(ctx context.Context, chatID, reminderID, userID int64, at time.Time) (bool, error) {
+	defer observe(ctx, "jobs.Snooze", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	const snooze = `
+UPDATE reminders SET snoozed_count = snoozed_count + 1, archived_at = NULL
+WHERE id=$1 AND COALESCE(reminder_rule, '') = '' AND acknowledged_at IS NULL
+  AND (assignee_id = $3 OR (chat_id = $2 AND (assignee_id IS NULL OR user_id = $3)))`
+	tag, err := tx.Exec(ctx, snooze, reminderID, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	const skipNags = `
+UPDATE reminder_jobs SET skipped_at=now(), last_error='snoozed'
+WHERE reminder_id=$1 AND nag_seq > 0 AND sent_at IS NULL AND skipped_at IS NULL AND claimed_at IS NULL`
+	if _, err := tx.Exec(ctx, skipNags, reminderID); err != nil {
+		return false, err
+	}
+	const ins = `
+INSERT INTO reminder_jobs (reminder_id, report_time)
+VALUES ($1,$2)
+ON CONFLICT (reminder_id, report_time) DO NOTHING`
+	if _, err := tx.Exec(ctx, ins, reminderID, at); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	r.notify()
+	return true, nil
+}
+
+func (r *jobsPG) NextDue(ctx context.Context) (*time.Time, error) {
+	defer observe(ctx, "jobs.NextDue", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT min(COALESCE(next_attempt_at, report_time))
+FROM reminder_jobs
+WHERE sent_at IS NULL AND failed_at IS NULL AND skipped_at IS NULL`
+	var t *time.Time
+	err := r.db.QueryRow(ctx, q).Scan(&t)
+	return t, err
+}
+
+// SkipNags закрывает ещё не отправленные повторы настойчивого напоминания,
+// например когда настойчивый режим выключили.
+func (r *jobsPG) SkipNags(ctx context.Context, reminderID int64, reason string) error {
+	defer observe(ctx, "jobs.SkipNags", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+UPDATE reminder_jobs SET skipped_at=now(), last_error=$2
+WHERE reminder_id=$1 AND nag_seq > 0 AND sent_at IS NULL AND skipped_at IS NULL`
+	_, err := r.db.Exec(ctx, q, reminderID, reason)
+	return err
+}
+
+// Claim захватывает job перед отправкой. Пока захват не снят, Due его не
+// возвращает, а next_attempt_at = confirmBy — когда Claimed отдаст его на
+// повторную запись sent_at, если MarkSent после отправки не пройдёт.
+// false — job уже захвачен или закрыт.
+func (r *jobsPG) Claim(ctx context.Context, jobID int64, confirmBy time.Time) (bool, error) {
+	defer observe(ctx, "jobs.Claim", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+UPDATE reminder_jobs SET claimed_at=now(), next_attempt_at=$2
+WHERE id=$1 AND sent_at IS NULL AND claimed_at IS NULL`
+	tag, err := r.db.Exec(ctx, q, jobID, confirmBy)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Release снимает захват, когда известно, что сообщение не ушло, и
+// возвращает job в очередь на at.
+func (r *jobsPG) Release(ctx context.Context, jobID int64, at time.Time) error {
+	defer observe(ctx, "jobs.Release", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `UPDATE reminder_jobs SET claimed_at=NULL, next_attempt_at=$2 WHERE id=$1 AND sent_at IS NULL`
+	_, err := r.db.Exec(ctx, q, jobID, at)
+	if err == nil {
+		r.notify()
+	}
+	return err
+}
+
+// Claimed — захваченные job'ы без sent_at, которым пора повторить запись
+// отправки: MarkSent после отправки не прошёл или бот упал между ними.
+func (r *jobsPG) Claimed(ctx context.Context, now time.Time, limit int) ([]Job, error) {
+	defer observe(ctx, "jobs.Claimed", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT ` + jobColumns + `
+FROM reminder_jobs j
+JOIN reminders r ON r.id=j.reminder_id
+WHERE j.sent_at IS NULL AND j.failed_at IS NULL AND j.skipped_at IS NULL AND j.claimed_at IS NOT NULL
+  AND j.next_attempt_at <= $1
+ORDER BY j.next_attempt_at
+LIMIT $2`
+	rows, err := r.db.Query(ctx, q, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
 type WeeklyEntry struct {
 	ID        int64
 	ChatID    int64
@@ -335,19 +1060,75 @@ type WeeklyEntry struct {
 	StartTime time.Time
 	EndTime   *time.Time
 	Title     string
+	// UserID — владелец записи в группе; nil — общее расписание чата.
+	UserID    *int64
+	OwnerName string
+	// Parity — чередование недель: ParityAny, ParityOdd или ParityEven.
+	Parity int
+	// ValidFrom, ValidTo — даты начала и конца действия записи включительно.
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+	Location  string
+	Notes     string
 }
 
 type WeeklyScheduleRepo interface {
-	Set(ctx context.Context, chatID int64, entries []WeeklyEntry) error
+	Set(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error
 	ListForWeekday(ctx context.Context, chatID int64, weekday int) ([]WeeklyEntry, error)
-	Clear(ctx context.Context, chatID int64) error
+	List(ctx context.Context, chatID int64) ([]WeeklyEntry, error)
+	Clear(ctx context.Context, chatID int64, userID *int64) error
+	Add(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error
+	ReplaceDays(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error
+	Delete(ctx context.Context, chatID int64, userID *int64, id int64) (bool, error)
+	Move(ctx context.Context, chatID int64, userID *int64, id int64, weekday int, start time.Time, end *time.Time) (bool, error)
 }
 
 type weeklySchedulePG struct{ db *pgxpool.Pool }
 
 func (s *Storage) Schedule() WeeklyScheduleRepo { return &weeklySchedulePG{s.pool} }
 
-func (r *weeklySchedulePG) Set(ctx context.Context, chatID int64, entries []WeeklyEntry) error {
+// Set заменяет записи одного владельца (userID=nil — общие записи чата),
+// не трогая расписание остальных участников.
+func (r *weeklySchedulePG) Set(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error {
+	defer observe(ctx, "weeklySchedule.Set", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM weekly_schedule WHERE chat_id=$1 AND user_id IS NOT DISTINCT FROM $2`, chatID, userID); err != nil {
+		return err
+	}
+	if err := insertWeekly(ctx, tx, chatID, userID, entries); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Add дописывает записи владельца, не трогая существующие.
+func (r *weeklySchedulePG) Add(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error {
+	defer observe(ctx, "weeklySchedule.Add", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertWeekly(ctx, tx, chatID, userID, entries); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ReplaceDays заменяет записи владельца только за те дни недели, которые
+// встречаются в entries; остальные дни остаются как были.
+func (r *weeklySchedulePG) ReplaceDays(ctx context.Context, chatID int64, userID *int64, entries []WeeklyEntry) error {
+	defer observe(ctx, "weeklySchedule.ReplaceDays", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	tx, err := r.db.Begin(ctx)
@@ -356,25 +1137,72 @@ func (r *weeklySchedulePG) Set(ctx context.Context, chatID int64, entries []Week
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx, `DELETE FROM weekly_schedule WHERE chat_id=$1`, chatID); err != nil {
+	days := make([]int32, 0, len(entries))
+	for _, e := range entries {
+		days = append(days, int32(e.Weekday))
+	}
+	const del = `
+DELETE FROM weekly_schedule
+WHERE chat_id=$1 AND user_id IS NOT DISTINCT FROM $2 AND weekday = ANY($3)`
+	if _, err := tx.Exec(ctx, del, chatID, userID, days); err != nil {
 		return err
 	}
+	if err := insertWeekly(ctx, tx, chatID, userID, entries); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func insertWeekly(ctx context.Context, tx pgx.Tx, chatID int64, userID *int64, entries []WeeklyEntry) error {
 	const ins = `
-INSERT INTO weekly_schedule (chat_id, weekday, start_time, end_time, title)
-VALUES ($1,$2,$3,$4,$5)`
+INSERT INTO weekly_schedule (chat_id, weekday, start_time, end_time, title, user_id, owner_name,
+                             week_parity, valid_from, valid_to, location, notes)
+VALUES ($1,$2,$3,$4,$5,$6,NULLIF($7,''),$8,$9,$10,NULLIF($11,''),NULLIF($12,''))`
 	for _, e := range entries {
-		if _, err := tx.Exec(ctx, ins, chatID, e.Weekday, e.StartTime.Format("15:04:05"), nilOrTime(e.EndTime), e.Title); err != nil {
+		if _, err := tx.Exec(ctx, ins, chatID, e.Weekday, e.StartTime.Format("15:04:05"), nilOrTime(e.EndTime), e.Title,
+			userID, e.OwnerName, e.Parity, e.ValidFrom, e.ValidTo, e.Location, e.Notes); err != nil {
 			return err
 		}
 	}
-	return tx.Commit(ctx)
+	return nil
+}
+
+// Delete удаляет запись по ID, если она принадлежит владельцу userID.
+func (r *weeklySchedulePG) Delete(ctx context.Context, chatID int64, userID *int64, id int64) (bool, error) {
+	defer observe(ctx, "weeklySchedule.Delete", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tag, err := r.db.Exec(ctx, `
+DELETE FROM weekly_schedule
+WHERE id=$1 AND chat_id=$2 AND user_id IS NOT DISTINCT FROM $3`, id, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Move переносит запись владельца на другой день и время.
+func (r *weeklySchedulePG) Move(ctx context.Context, chatID int64, userID *int64, id int64, weekday int, start time.Time, end *time.Time) (bool, error) {
+	defer observe(ctx, "weeklySchedule.Move", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	tag, err := r.db.Exec(ctx, `
+UPDATE weekly_schedule
+SET weekday=$4, start_time=$5, end_time=$6
+WHERE id=$1 AND chat_id=$2 AND user_id IS NOT DISTINCT FROM $3`,
+		id, chatID, userID, weekday, start.Format("15:04:05"), nilOrTime(end))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
 }
 
 func (r *weeklySchedulePG) ListForWeekday(ctx context.Context, chatID int64, weekday int) ([]WeeklyEntry, error) {
+	defer observe(ctx, "weeklySchedule.ListForWeekday", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	const q = `
-SELECT id, chat_id, weekday, start_time, end_time, title
+SELECT ` + weeklyColumns + `
 FROM weekly_schedule
 WHERE chat_id=$1 AND weekday=$2
 ORDER BY start_time`
@@ -382,12 +1210,36 @@ ORDER BY start_time`
 	if err != nil {
 		return nil, err
 	}
+	return scanWeekly(rows)
+}
+
+func (r *weeklySchedulePG) List(ctx context.Context, chatID int64) ([]WeeklyEntry, error) {
+	defer observe(ctx, "weeklySchedule.List", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT ` + weeklyColumns + `
+FROM weekly_schedule
+WHERE chat_id=$1
+ORDER BY weekday, start_time`
+	rows, err := r.db.Query(ctx, q, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return scanWeekly(rows)
+}
+
+const weeklyColumns = `id, chat_id, weekday, start_time, end_time, title, user_id, COALESCE(owner_name, ''),
+       week_parity, valid_from, valid_to, COALESCE(location, ''), COALESCE(notes, '')`
+
+func scanWeekly(rows pgx.Rows) ([]WeeklyEntry, error) {
 	defer rows.Close()
 	var out []WeeklyEntry
 	for rows.Next() {
 		var e WeeklyEntry
 		var st, et *time.Time
-		if err := rows.Scan(&e.ID, &e.ChatID, &e.Weekday, &st, &et, &e.Title); err != nil {
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.Weekday, &st, &et, &e.Title, &e.UserID, &e.OwnerName,
+			&e.Parity, &e.ValidFrom, &e.ValidTo, &e.Location, &e.Notes); err != nil {
 			return nil, err
 		}
 		if st != nil {
@@ -399,10 +1251,12 @@ ORDER BY start_time`
 	return out, rows.Err()
 }
 
-func (r *weeklySchedulePG) Clear(ctx context.Context, chatID int64) error {
+// Clear удаляет записи одного владельца, как Set.
+func (r *weeklySchedulePG) Clear(ctx context.Context, chatID int64, userID *int64) error {
+	defer observe(ctx, "weeklySchedule.Clear", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	_, err := r.db.Exec(ctx, `DELETE FROM weekly_schedule WHERE chat_id=$1`, chatID)
+	_, err := r.db.Exec(ctx, `DELETE FROM weekly_schedule WHERE chat_id=$1 AND user_id IS NOT DISTINCT FROM $2`, chatID, userID)
 	return err
 }
 
@@ -455,29 +1309,26 @@ func LoadFixedUTC(name string) (*time.Location, bool) {
 	return time.FixedZone(fmt.Sprintf("UTC%+02d:%02d", sign*hh, mm), offset), true
 }
 
+var ruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// NextFromWeeklyRRULE — ближайшее после now срабатывание правила вида
+// FREQ=WEEKLY;BYDAY=MO[,WE…];BYHOUR=9;BYMINUTE=0. Без BYDAY (FREQ=DAILY)
+// подходит любой день.
 func NextFromWeeklyRRULE(rrule string, tz string, now time.Time) time.Time {
 	loc := LoadUserLocation(tz)
 	parts := strings.Split(rrule, ";")
-	var weekday time.Weekday
+	days := map[time.Weekday]bool{}
 	var hour, min int
 
 	for _, p := range parts {
 		if strings.HasPrefix(p, "BYDAY=") {
-			switch strings.TrimPrefix(p, "BYDAY=") {
-			case "MO":
-				weekday = time.Monday
-			case "TU":
-				weekday = time.Tuesday
-			case "WE":
-				weekday = time.Wednesday
-			case "TH":
-				weekday = time.Thursday
-			case "FR":
-				weekday = time.Friday
-			case "SA":
-				weekday = time.Saturday
-			case "SU":
-				weekday = time.Sunday
+			for _, d := range strings.Split(strings.TrimPrefix(p, "BYDAY="), ",") {
+				if wd, ok := ruleWeekdays[d]; ok {
+					days[wd] = true
+				}
 			}
 		}
 		if strings.HasPrefix(p, "BYHOUR=") {
@@ -490,7 +1341,7 @@ func NextFromWeeklyRRULE(rrule string, tz string, now time.Time) time.Time {
 
 	nowLocal := now.In(loc)
 	candidate := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), hour, min, 0, 0, loc)
-	for candidate.Weekday() != weekday || !candidate.After(nowLocal) {
+	for (len(days) > 0 && !days[candidate.Weekday()]) || !candidate.After(nowLocal) {
 		candidate = candidate.AddDate(0, 0, 1)
 	}
 	return candidate.UTC()
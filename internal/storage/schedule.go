@@ -0,0 +1,67 @@
+package storage
+
+import "time"
+
+// Чередование недель в расписании.
+const (
+	ParityAny  = 0
+	ParityOdd  = 1 // числитель
+	ParityEven = 2 // знаменатель
+)
+
+// ISOWeekday — день недели с понедельника: 1 — Пн, 7 — Вс.
+func ISOWeekday(t time.Time) int {
+	wd := int(t.Weekday())
+	if wd == 0 {
+		return 7
+	}
+	return wd
+}
+
+// dateOf — календарная дата t без учёта зоны, для сравнения с полями date.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parityAnchor — понедельник первой недели (числителя) для записей без
+// начала периода. Недели считаются подряд от одной даты, а не по ISO-номеру:
+// после года из 53 недель ISO-нумерация даёт две нечётные недели подряд, а
+// календарь выгружает чередование как строгое INTERVAL=2. До конца 2026 года
+// счёт совпадает с ISO-номерами.
+var parityAnchor = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// WeekParity возвращает чётность недели, в которую попадает day. Неделя с
+// началом периода from — первая (числитель); без него недели считаются от
+// parityAnchor.
+func WeekParity(day time.Time, from *time.Time) int {
+	f := parityAnchor
+	if from != nil {
+		f = dateOf(*from)
+	}
+	d := dateOf(day)
+	d = d.AddDate(0, 0, 1-ISOWeekday(d))
+	f = f.AddDate(0, 0, 1-ISOWeekday(f))
+	// разница — целое число недель, поэтому и для дат раньше f чётность
+	// чередуется без сбоя
+	n := int(d.Sub(f).Hours()/24)/7 + 1
+	if n%2 != 0 {
+		return ParityOdd
+	}
+	return ParityEven
+}
+
+// ActiveOn сообщает, действует ли запись в день day (дата в зоне чата):
+// совпадает день недели, дата входит в период и неделя нужной чётности.
+func (e WeeklyEntry) ActiveOn(day time.Time) bool {
+	if ISOWeekday(day) != e.Weekday {
+		return false
+	}
+	d := dateOf(day)
+	if e.ValidFrom != nil && d.Before(dateOf(*e.ValidFrom)) {
+		return false
+	}
+	if e.ValidTo != nil && d.After(dateOf(*e.ValidTo)) {
+		return false
+	}
+	return e.Parity == ParityAny || WeekParity(day, e.ValidFrom) == e.Parity
+}
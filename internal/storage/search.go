@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Виды результатов поиска в порядке выдачи.
+const (
+	SearchReminder = 0 // активное напоминание
+	SearchSchedule = 1 // запись недельного расписания
+	SearchHistory  = 2 // напоминание из архива
+)
+
+// SearchHit — один результат поиска: Reminder для SearchReminder и
+// SearchHistory, Entry для SearchSchedule.
+type SearchHit struct {
+	Kind     int
+	Reminder *Reminder
+	Entry    *WeeklyEntry
+}
+
+type SearchRepo interface {
+	Search(ctx context.Context, chatID int64, query string, offset, limit int) ([]SearchHit, int, error)
+}
+
+type searchPG struct{ db *pgxpool.Pool }
+
+func (s *Storage) Search() SearchRepo { return &searchPG{s.pool} }
+
+// Search ищет query по тексту напоминаний и названиям занятий чата
+// полнотекстовым поиском: запрос разбирается и русской, и английской
+// конфигурацией, совпадение по любой из них засчитывается. Выдача: сначала
+// активные напоминания, потом расписание, потом архив, внутри — по
+// релевантности. Возвращает страницу [offset, offset+limit) и общее число
+// найденного.
+func (r *searchPG) Search(ctx context.Context, chatID int64, query string, offset, limit int) ([]SearchHit, int, error) {
+	defer observe(ctx, "search.Search", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const q = `
+WITH q AS (SELECT plainto_tsquery('russian', $2) || plainto_tsquery('english', $2) AS q)
+SELECT kind, id, count(*) OVER ()
+FROM (
+    SELECT CASE WHEN r.archived_at IS NULL THEN 0 ELSE 2 END AS kind, r.id,
+           ts_rank(to_tsvector('russian', r.message) || to_tsvector('english', r.message), q.q) AS rank,
+           COALESCE(r.fired_at, r.archived_at, r.event_time, r.next_report) AS at
+    FROM reminders r, q
+    WHERE r.chat_id=$1 AND (to_tsvector('russian', r.message) || to_tsvector('english', r.message)) @@ q.q
+    UNION ALL
+    SELECT 1, w.id,
+           ts_rank(to_tsvector('russian', w.title) || to_tsvector('english', w.title), q.q),
+           NULL
+    FROM weekly_schedule w, q
+    WHERE w.chat_id=$1 AND (to_tsvector('russian', w.title) || to_tsvector('english', w.title)) @@ q.q
+) hits
+ORDER BY kind, rank DESC, at DESC NULLS LAST, id
+OFFSET $3 LIMIT $4`
+	rows, err := r.db.Query(ctx, q, chatID, query, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	type ref struct {
+		kind int
+		id   int64
+	}
+	var refs []ref
+	var remIDs, weeklyIDs []int64
+	total := 0
+	for rows.Next() {
+		var x ref
+		if err := rows.Scan(&x.kind, &x.id, &total); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		refs = append(refs, x)
+		if x.kind == SearchSchedule {
+			weeklyIDs = append(weeklyIDs, x.id)
+		} else {
+			remIDs = append(remIDs, x.id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(refs) == 0 {
+		return nil, total, nil
+	}
+
+	reminders := map[int64]*Reminder{}
+	if len(remIDs) > 0 {
+		rows, err := r.db.Query(ctx, `SELECT `+reminderColumns+` FROM reminders WHERE chat_id=$1 AND id = ANY($2)`, chatID, remIDs)
+		if err != nil {
+			return nil, 0, err
+		}
+		list, err := scanReminders(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := range list {
+			reminders[list[i].ID] = &list[i]
+		}
+	}
+	entries := map[int64]*WeeklyEntry{}
+	if len(weeklyIDs) > 0 {
+		rows, err := r.db.Query(ctx, `SELECT `+weeklyColumns+` FROM weekly_schedule WHERE chat_id=$1 AND id = ANY($2)`, chatID, weeklyIDs)
+		if err != nil {
+			return nil, 0, err
+		}
+		list, err := scanWeekly(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := range list {
+			entries[list[i].ID] = &list[i]
+		}
+	}
+
+	// строки, удалённые между запросами, просто пропускаются
+	hits := make([]SearchHit, 0, len(refs))
+	for _, x := range refs {
+		h := SearchHit{Kind: x.kind}
+		if x.kind == SearchSchedule {
+			if h.Entry = entries[x.id]; h.Entry == nil {
+				continue
+			}
+		} else if h.Reminder = reminders[x.id]; h.Reminder == nil {
+			continue
+		}
+		hits = append(hits, h)
+	}
+	return hits, total, nil
+}
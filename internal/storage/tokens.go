@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TokensRepo interface {
+	Issue(ctx context.Context, chatID int64) (string, error)
+	RevokeAll(ctx context.Context, chatID int64) (int64, error)
+	Revoke(ctx context.Context, token string) error
+	ChatByToken(ctx context.Context, token string) (int64, bool, error)
+}
+
+type tokensPG struct{ db *pgxpool.Pool }
+
+func (s *Storage) Tokens() TokensRepo { return &tokensPG{s.pool} }
+
+// NewSecret возвращает случайную строку, пригодную для токенов и секретных URL.
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue выпускает новый токен для чата. Сам токен в БД не хранится, поэтому
+// показать его можно только один раз.
+func (r *tokensPG) Issue(ctx context.Context, chatID int64) (string, error) {
+	defer observe(ctx, "tokens.Issue", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	token, err := NewSecret()
+	if err != nil {
+		return "", err
+	}
+	const q = `INSERT INTO api_tokens (token_hash, chat_id) VALUES ($1,$2)`
+	if _, err := r.db.Exec(ctx, q, hashToken(token), chatID); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (r *tokensPG) RevokeAll(ctx context.Context, chatID int64) (int64, error) {
+	defer observe(ctx, "tokens.RevokeAll", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `UPDATE api_tokens SET revoked_at=now() WHERE chat_id=$1 AND revoked_at IS NULL`
+	tag, err := r.db.Exec(ctx, q, chatID)
+	return tag.RowsAffected(), err
+}
+
+// Revoke отзывает один токен, например если его не удалось доставить.
+func (r *tokensPG) Revoke(ctx context.Context, token string) error {
+	defer observe(ctx, "tokens.Revoke", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := r.db.Exec(ctx, `UPDATE api_tokens SET revoked_at=now() WHERE token_hash=$1 AND revoked_at IS NULL`, hashToken(token))
+	return err
+}
+
+func (r *tokensPG) ChatByToken(ctx context.Context, token string) (int64, bool, error) {
+	defer observe(ctx, "tokens.ChatByToken", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `SELECT chat_id FROM api_tokens WHERE token_hash=$1 AND revoked_at IS NULL`
+	var chatID int64
+	err := r.db.QueryRow(ctx, q, hashToken(token)).Scan(&chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return chatID, true, nil
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ChatDump — все данные одного чата для выгрузки и переноса: настройки,
+// напоминания, их job'ы (и ожидающие, и отработавшие), недельное расписание
+// и разовые изменения. Токены API и календаря не выгружаются.
+type ChatDump struct {
+	// ChatID — чат, из которого сделана выгрузка.
+	ChatID int64
+	// Settings — nil, если чат ничего не настраивал.
+	Settings   *ChatSettings
+	Reminders  []Reminder
+	Jobs       []JobRecord
+	Schedule   []WeeklyEntry
+	Exceptions []ScheduleException
+}
+
+// JobRecord — строка reminder_jobs как есть, без полей напоминания.
+type JobRecord struct {
+	ReminderID    int64
+	ReportTime    time.Time
+	SentAt        *time.Time
+	SkippedAt     *time.Time
+	FailedAt      *time.Time
+	NextAttemptAt *time.Time
+	Attempts      int
+	LastError     *string
+	NagSeq        int
+}
+
+// RestoreStats — сколько строк записал Restore.
+type RestoreStats struct {
+	Reminders  int
+	Jobs       int
+	Schedule   int
+	Exceptions int
+}
+
+type TransferRepo interface {
+	Dump(ctx context.Context, chatID int64) (ChatDump, error)
+	Restore(ctx context.Context, chatID int64, d ChatDump) (RestoreStats, error)
+}
+
+type transferPG struct {
+	db     *pgxpool.Pool
+	notify func()
+}
+
+func (s *Storage) Transfer() TransferRepo { return &transferPG{s.pool, s.signalJobs} }
+
+// Dump читает данные чата в одной транзакции, чтобы напоминания и их job'ы
+// были согласованы между собой.
+func (r *transferPG) Dump(ctx context.Context, chatID int64) (ChatDump, error) {
+	defer observe(ctx, "transfer.Dump", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	d := ChatDump{ChatID: chatID}
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	var cs ChatSettings
+	err = tx.QueryRow(ctx, `
+SELECT chat_id, time_zone, locale_language, daily_report_time, quiet_from, quiet_to, quiet_mode, holidays
+FROM chat_settings WHERE chat_id=$1`, chatID).Scan(&cs.ChatID, &cs.TimeZone, &cs.LocaleLanguage, &cs.DailyReportTime,
+		&cs.QuietFrom, &cs.QuietTo, &cs.QuietMode, &cs.Holidays)
+	switch {
+	case err == nil:
+		d.Settings = &cs
+	case !errors.Is(err, pgx.ErrNoRows):
+		return d, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+reminderColumns+` FROM reminders WHERE chat_id=$1 ORDER BY id`, chatID)
+	if err != nil {
+		return d, err
+	}
+	if d.Reminders, err = scanReminders(rows); err != nil {
+		return d, err
+	}
+
+	rows, err = tx.Query(ctx, `
+SELECT j.reminder_id, j.report_time, j.sent_at, j.skipped_at, j.failed_at, j.next_attempt_at,
+       j.attempts, j.last_error, j.nag_seq
+FROM reminder_jobs j JOIN reminders r ON r.id = j.reminder_id
+WHERE r.chat_id=$1
+ORDER BY j.reminder_id, j.report_time, j.nag_seq`, chatID)
+	if err != nil {
+		return d, err
+	}
+	for rows.Next() {
+		var j JobRecord
+		if err := rows.Scan(&j.ReminderID, &j.ReportTime, &j.SentAt, &j.SkippedAt, &j.FailedAt, &j.NextAttemptAt,
+			&j.Attempts, &j.LastError, &j.NagSeq); err != nil {
+			rows.Close()
+			return d, err
+		}
+		d.Jobs = append(d.Jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+weeklyColumns+` FROM weekly_schedule WHERE chat_id=$1 ORDER BY weekday, start_time, id`, chatID)
+	if err != nil {
+		return d, err
+	}
+	if d.Schedule, err = scanWeekly(rows); err != nil {
+		return d, err
+	}
+
+	rows, err = tx.Query(ctx, `
+SELECT id, chat_id, user_id, COALESCE(owner_name, ''), on_date, kind, start_time, end_time, title
+FROM schedule_exceptions
+WHERE chat_id=$1
+ORDER BY on_date, start_time NULLS FIRST, id`, chatID)
+	if err != nil {
+		return d, err
+	}
+	if d.Exceptions, err = scanExceptions(rows); err != nil {
+		return d, err
+	}
+	return d, tx.Commit(ctx)
+}
+
+// Restore записывает выгрузку в чат chatID одной транзакцией: настройки
+// заменяются, напоминания, job'ы, расписание и исключения добавляются к
+// существующим. ID напоминаний выдаются заново, job'ы привязываются к новым
+// ID; job'ы напоминаний, которых нет в выгрузке, пропускаются. Если выгрузка
+// из другого чата, авторы и исполнители напоминаний и владельцы записей
+// расписания и исключений не переносятся: их ID относятся к участникам того чата.
+func (r *transferPG) Restore(ctx context.Context, chatID int64, d ChatDump) (RestoreStats, error) {
+	defer observe(ctx, "transfer.Restore", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var st RestoreStats
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return st, err
+	}
+	defer tx.Rollback(ctx)
+
+	if cs := d.Settings; cs != nil {
+		const q = `
+INSERT INTO chat_settings (chat_id, time_zone, locale_language, daily_report_time, quiet_from, quiet_to, quiet_mode, holidays)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+ON CONFLICT (chat_id) DO UPDATE SET time_zone=EXCLUDED.time_zone, locale_language=EXCLUDED.locale_language,
+    daily_report_time=EXCLUDED.daily_report_time, quiet_from=EXCLUDED.quiet_from, quiet_to=EXCLUDED.quiet_to,
+    quiet_mode=EXCLUDED.quiet_mode, holidays=EXCLUDED.holidays`
+		if _, err := tx.Exec(ctx, q, chatID, cs.TimeZone, cs.LocaleLanguage, cs.DailyReportTime,
+			cs.QuietFrom, cs.QuietTo, cs.QuietMode, cs.Holidays); err != nil {
+			return st, err
+		}
+	}
+
+	const insRem = `
+INSERT INTO reminders (chat_id, message, event_time, reminder_time, reminder_offsets, reminder_rule, next_report,
+                       created_at, persistent, nag_interval, nag_max, acknowledged_at, user_id, author_name, broadcast,
+                       assignee_id, assignee_name, fired_at, archived_at, snoozed_count)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,NULLIF($14,''),$15,$16,NULLIF($17,''),$18,$19,$20)
+RETURNING id`
+	ids := make(map[int64]int64, len(d.Reminders))
+	for _, m := range d.Reminders {
+		offsets := m.ReminderOffsets
+		if len(offsets) == 0 {
+			offsets = []int{m.ReminderTime}
+		}
+		created := m.CreatedAt
+		if created.IsZero() {
+			created = time.Now()
+		}
+		if d.ChatID != chatID {
+			m.UserID, m.AssigneeID, m.AssigneeName = nil, nil, ""
+		}
+		var id int64
+		if err := tx.QueryRow(ctx, insRem, chatID, m.Message, m.EventTime, offsets[0], offsets, m.ReminderRule, m.NextReport,
+			created, m.Persistent, m.NagInterval, m.NagMax, m.AcknowledgedAt, m.UserID, m.AuthorName, m.Broadcast,
+			m.AssigneeID, m.AssigneeName, m.FiredAt, m.ArchivedAt, m.SnoozedCount).Scan(&id); err != nil {
+			return st, err
+		}
+		ids[m.ID] = id
+		st.Reminders++
+	}
+
+	const insJob = `
+INSERT INTO reminder_jobs (reminder_id, report_time, sent_at, skipped_at, failed_at, next_attempt_at,
+                           attempts, last_error, nag_seq)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+ON CONFLICT DO NOTHING`
+	for _, j := range d.Jobs {
+		id, ok := ids[j.ReminderID]
+		if !ok {
+			continue
+		}
+		tag, err := tx.Exec(ctx, insJob, id, j.ReportTime, j.SentAt, j.SkippedAt, j.FailedAt, j.NextAttemptAt,
+			j.Attempts, j.LastError, j.NagSeq)
+		if err != nil {
+			return st, err
+		}
+		st.Jobs += int(tag.RowsAffected())
+	}
+
+	for _, e := range d.Schedule {
+		if d.ChatID != chatID {
+			e.UserID, e.OwnerName = nil, ""
+		}
+		if err := insertWeekly(ctx, tx, chatID, e.UserID, []WeeklyEntry{e}); err != nil {
+			return st, err
+		}
+		st.Schedule++
+	}
+
+	const insEx = `
+INSERT INTO schedule_exceptions (chat_id, user_id, owner_name, on_date, kind, start_time, end_time, title)
+VALUES ($1,$2,NULLIF($3,''),$4,$5,$6,$7,$8)`
+	for _, x := range d.Exceptions {
+		if d.ChatID != chatID {
+			x.UserID, x.OwnerName = nil, ""
+		}
+		if _, err := tx.Exec(ctx, insEx, chatID, x.UserID, x.OwnerName, dateOf(x.Date), x.Kind,
+			nilOrClock(x.StartTime), nilOrClock(x.EndTime), x.Title); err != nil {
+			return st, err
+		}
+		st.Exceptions++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return st, err
+	}
+	if st.Jobs > 0 {
+		r.notify()
+	}
+	return st, nil
+}
+
+// nilOrClock — время суток для колонки time или NULL.
+func nilOrClock(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format("15:04:05")
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type KnownUser struct {
+	ID             int64
+	Username       string
+	FirstName      string
+	LastName       string
+	HasPrivateChat bool
+}
+
+// DisplayName — имя для упоминаний: имя и фамилия, иначе username.
+func (u KnownUser) DisplayName() string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.Username
+	}
+	return name
+}
+
+type UsersRepo interface {
+	Touch(ctx context.Context, u KnownUser, private bool) error
+	ByUsername(ctx context.Context, username string) (KnownUser, bool, error)
+	SetPrivateChat(ctx context.Context, userID int64, ok bool) error
+}
+
+type usersPG struct{ db *pgxpool.Pool }
+
+func (s *Storage) Users() UsersRepo { return &usersPG{s.pool} }
+
+// Touch запоминает пользователя. private=true — сообщение пришло в личку,
+// значит, бот запущен и может писать ему напрямую.
+func (r *usersPG) Touch(ctx context.Context, u KnownUser, private bool) error {
+	defer observe(ctx, "users.Touch", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+INSERT INTO known_users (user_id, username, first_name, last_name, has_private_chat)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET username = EXCLUDED.username,
+    first_name = EXCLUDED.first_name,
+    last_name = EXCLUDED.last_name,
+    has_private_chat = known_users.has_private_chat OR EXCLUDED.has_private_chat,
+    updated_at = now()`
+	_, err := r.db.Exec(ctx, q, u.ID, u.Username, u.FirstName, u.LastName, private)
+	return err
+}
+
+// ByUsername ищет пользователя по @username без учёта регистра. Если имя
+// переходило от одного пользователя к другому, берётся последний увиденный.
+func (r *usersPG) ByUsername(ctx context.Context, username string) (KnownUser, bool, error) {
+	defer observe(ctx, "users.ByUsername", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	const q = `
+SELECT user_id, COALESCE(username, ''), first_name, last_name, has_private_chat
+FROM known_users
+WHERE lower(username) = lower($1)
+ORDER BY updated_at DESC
+LIMIT 1`
+	var u KnownUser
+	err := r.db.QueryRow(ctx, q, strings.TrimPrefix(username, "@")).
+		Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &u.HasPrivateChat)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return KnownUser{}, false, nil
+	}
+	return u, err == nil, err
+}
+
+// SetPrivateChat отмечает, можно ли писать пользователю в личку: false
+// ставится, когда он заблокировал бота.
+func (r *usersPG) SetPrivateChat(ctx context.Context, userID int64, ok bool) error {
+	defer observe(ctx, "users.SetPrivateChat", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := r.db.Exec(ctx, `UPDATE known_users SET has_private_chat=$2, updated_at=now() WHERE user_id=$1`, userID, ok)
+	return err
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"TelegramBot/internal/config"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleJobs — админская команда для работы с упавшими job'ами:
+// /jobs failed — список, /jobs requeue <id|all> — вернуть в очередь.
+// Доступна только чатам из ADMIN_CHAT_IDS.
+func HandleJobs(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cfg config.Config, chatID int64, rest string) {
+	if !cfg.IsAdmin(chatID) {
+		Reply(bot, chatID, "Нет доступа")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	parts := strings.Fields(rest)
+	if len(parts) == 0 {
+		Reply(bot, chatID, "Использование:\n/jobs failed\n/jobs requeue <id> | all")
+		return
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "failed":
+		jobs, err := store.Jobs().Failed(ctx, 50)
+		if err != nil {
+			logging.FromContext(ctx).Error("load dead-letter jobs failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось получить список")
+			return
+		}
+		if len(jobs) == 0 {
+			Reply(bot, chatID, "Упавших задач нет")
+			return
+		}
+		var b strings.Builder
+		for _, j := range jobs {
+			lastErr := ""
+			if j.LastError != nil {
+				lastErr = *j.LastError
+			}
+			fmt.Fprintf(&b, "#%d chat=%d попыток=%d %s UTC — %s\n  %s\n",
+				j.ID, j.ChatID, j.Attempts, j.ReportTime.UTC().Format("02 Jan 15:04"), j.Message, lastErr)
+		}
+		Reply(bot, chatID, b.String())
+
+	case "requeue":
+		if len(parts) < 2 {
+			Reply(bot, chatID, "Пример: /jobs requeue 42 или /jobs requeue all")
+			return
+		}
+		var ids []int64
+		if strings.ToLower(parts[1]) == "all" {
+			jobs, err := store.Jobs().Failed(ctx, 1000)
+			if err != nil {
+				logging.FromContext(ctx).Error("load dead-letter jobs failed", logging.Err(err))
+				Reply(bot, chatID, "Не удалось получить список")
+				return
+			}
+			for _, j := range jobs {
+				ids = append(ids, j.ID)
+			}
+		} else {
+			id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "#"), 10, 64)
+			if err != nil {
+				Reply(bot, chatID, "Неверный id задачи")
+				return
+			}
+			ids = append(ids, id)
+		}
+		n := 0
+		for _, id := range ids {
+			ok, err := store.Jobs().Requeue(ctx, id)
+			if err != nil {
+				logging.FromContext(ctx).Error("requeue job failed", "job_id", id, logging.Err(err))
+				continue
+			}
+			if ok {
+				n++
+			}
+		}
+		Reply(bot, chatID, fmt.Sprintf("Возвращено в очередь: %d", n))
+
+	default:
+		Reply(bot, chatID, "Неизвестная подкоманда. Использование:\n/jobs failed | requeue ...")
+	}
+}
@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleCalendar выдаёт ссылку на ICS-ленту чата (/calendar) или выпускает
+// новую, делая старую недействительной (/calendar reset).
+func HandleCalendar(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, selfURL string, chatID int64, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var reset bool
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "":
+	case "reset":
+		reset = true
+	default:
+		Reply(bot, chatID, "Использование:\n/calendar — ссылка на календарь\n/calendar reset — сменить ссылку")
+		return
+	}
+
+	token, err := store.ChatSettings().CalendarToken(ctx, chatID, reset)
+	if err != nil {
+		logging.FromContext(ctx).Error("calendar token failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось получить ссылку на календарь")
+		return
+	}
+
+	url := strings.TrimRight(selfURL, "/") + "/calendar/" + token + ".ics"
+	webcal := url
+	if i := strings.Index(url, "://"); i >= 0 {
+		webcal = "webcal" + url[i:]
+	}
+	msg := "Календарь с напоминаниями и расписанием:\n%s\n\nДля подписки в Google Calendar, Apple Calendar или Outlook:\n%s\n\nСсылка секретная: кто её знает, видит ваш календарь. Сменить: /calendar reset"
+	if reset {
+		msg = "Старая ссылка больше не работает.\n\n" + msg
+	}
+	Reply(bot, chatID, fmt.Sprintf(msg, url, webcal))
+}
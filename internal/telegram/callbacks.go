@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// snoozeDelay — на сколько откладывает кнопка «+10 мин».
+const snoozeDelay = 10 * time.Minute
+
+// reminderKeyboard — кнопки под напоминанием: «Готово» у настойчивого,
+// «+10 мин» у разового. false — кнопок нет.
+func reminderKeyboard(j storage.Job) (tgbotapi.InlineKeyboardMarkup, bool) {
+	var row []tgbotapi.InlineKeyboardButton
+	if j.Persistent {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("✅ Готово", fmt.Sprintf("done:%d", j.ReminderID)))
+	}
+	if j.ReminderRule == nil || *j.ReminderRule == "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("⏰ +10 мин", fmt.Sprintf("sn:%d", j.ReminderID)))
+	}
+	if len(row) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), true
+}
+
+func answerCallback(ctx context.Context, bot *tgbotapi.BotAPI, callbackID, text string) {
+	if _, err := bot.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		logging.FromContext(ctx).Warn("callback answer failed", logging.Err(err))
+	}
+}
+
+// HandleCallback разбирает нажатия inline-кнопок. Данные кнопки имеют вид
+// "<действие>:<аргумент>".
+func HandleCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery) {
+	if cq.Message == nil {
+		answerCallback(ctx, bot, cq.ID, "")
+		return
+	}
+	action, arg, _ := strings.Cut(cq.Data, ":")
+	label := "callback:other"
+	if action == "done" || action == "sn" || action == "ics" || action == "rc" || action == "tt" || action == "bk" || action == "fg" || action == "fd" {
+		label = "callback:" + action
+	}
+	defer metrics.ObserveSince(metrics.HandlerDuration.WithLabelValues(label), time.Now())
+
+	switch action {
+	case "done":
+		handleDone(ctx, bot, store, cq, arg)
+	case "sn":
+		handleSnooze(ctx, bot, store, cq, arg)
+	case "ics":
+		handleImportCallback(ctx, bot, store, cq, arg)
+	case "rc":
+		handleConflictCallback(ctx, bot, store, cq, arg)
+	case "tt":
+		handleTimetableImportCallback(ctx, bot, store, cq, arg)
+	case "bk":
+		handleBackupCallback(ctx, bot, store, cq, arg)
+	case "fg":
+		handleForgetCallback(ctx, bot, store, cq, arg)
+	case "fd":
+		handleFindCallback(ctx, bot, store, cq, arg)
+	default:
+		answerCallback(ctx, bot, cq.ID, "")
+	}
+}
+
+func handleDone(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	chatID := cq.Message.Chat.ID
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		answerCallback(ctx, bot, cq.ID, "")
+		return
+	}
+	ok, err := store.Reminders().Acknowledge(ctx, chatID, id, cq.From.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("acknowledge failed", "reminder_id", id, logging.Err(err))
+		answerCallback(ctx, bot, cq.ID, "Не удалось сохранить, попробуй ещё раз")
+		return
+	}
+	if !ok {
+		answerCallback(ctx, bot, cq.ID, "Напоминание не активно или назначено не тебе")
+		return
+	}
+	_ = store.Reminders().ArchiveIfNoPending(ctx, id)
+
+	answerCallback(ctx, bot, cq.ID, "Отмечено ✅")
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n✅ Выполнено")
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
+
+// handleSnooze: "<reminderID>" — присылает разовое напоминание ещё раз
+// через snoozeDelay.
+func handleSnooze(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	chatID := cq.Message.Chat.ID
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		answerCallback(ctx, bot, cq.ID, "")
+		return
+	}
+	at := time.Now().Add(snoozeDelay).Truncate(time.Minute)
+	ok, err := store.Jobs().Snooze(ctx, chatID, id, cq.From.ID, at.UTC())
+	if err != nil {
+		logging.FromContext(ctx).Error("snooze failed", "reminder_id", id, logging.Err(err))
+		answerCallback(ctx, bot, cq.ID, "Не удалось сохранить, попробуй ещё раз")
+		return
+	}
+	if !ok {
+		answerCallback(ctx, bot, cq.ID, "Напоминание уже выполнено или назначено не тебе")
+		return
+	}
+
+	cs, _ := store.ChatSettings().Get(ctx, chatID)
+	answerCallback(ctx, bot, cq.ID, "Напомню через 10 минут")
+	// без кнопок: повторное нажатие отложило бы ещё раз
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID,
+		cq.Message.Text+"\n⏰ Отложено до "+at.In(storage.LoadUserLocation(cs.TimeZone)).Format("15:04"))
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -1,11 +1,16 @@
 package telegram
 
 import (
+	"TelegramBot/internal/config"
+	"TelegramBot/internal/holidays"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
 	"TelegramBot/internal/storage"
 	"TelegramBot/internal/timeparse"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -15,7 +20,7 @@ import (
 func Reply(bot *tgbotapi.BotAPI, chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	if _, err := bot.Send(msg); err != nil {
-		log.Printf("reply send error (chatID=%d): %v", chatID, err)
+		slog.Error("reply send failed", "chat_id", chatID, logging.Err(err))
 	}
 }
 func buildReplyKB() tgbotapi.ReplyKeyboardMarkup {
@@ -49,14 +54,22 @@ func showHome(bot *tgbotapi.BotAPI, chatID int64) {
 	bot.Send(msg)
 }
 
-func HandleMessage(bot *tgbotapi.BotAPI, store *storage.Storage, message *tgbotapi.Message) {
+func HandleMessage(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cfg config.Config, message *tgbotapi.Message) {
+	defer metrics.ObserveSince(metrics.HandlerDuration.WithLabelValues(commandLabel(message)), time.Now())
 	chatId := message.Chat.ID
-	text := strings.TrimSpace(message.Text)
+	trackUser(ctx, store, message)
+	text, ok := addressedText(bot, message)
+	if !ok {
+		return
+	}
 
 	switch {
+	case message.Document != nil:
+		HandleDocument(ctx, bot, store, message)
+
 	case strings.HasPrefix(text, "/start"):
 		showHome(bot, chatId)
-		Reply(bot, chatId, "Привет! Я — твой персональный помощник и ассистент от Александра.\nУ меня есть несколько команд, которые я могу выполнить:\n• /timezone — установить часовой пояс\n• /report 20:00 — включить ежедневный отчёт \n• /list today | week | all — показать запланированные дела\n• /timetable — задать расписание\nА ещё можно просто написать: «во вторник в 14:00 встреча за 30 минут» и я напомню тебе о ней")
+		Reply(bot, chatId, "Привет! Я — твой персональный помощник и ассистент от Александра.\nУ меня есть несколько команд, которые я могу выполнить:\n• /timezone — установить часовой пояс\n• /report 20:00 — включить ежедневный отчёт \n• /quiet 23:00-08:00 — тихие часы\n• /list today | week | all — показать запланированные дела\n• /history today | week | month — что уже напомнил\n• /find стоматолог — поиск по напоминаниям и расписанию\n• /timetable — задать расписание\n• /calendar — подписаться на календарь\n• /export — выгрузить все данные чата\n• /forget — удалить все данные чата\nА ещё можно просто написать: «во вторник в 14:00 встреча за 30 минут» и я напомню тебе о ней")
 
 	case strings.HasPrefix(text, "/timezone"):
 		timezone := strings.TrimSpace(strings.TrimPrefix(text, "/timezone"))
@@ -64,7 +77,7 @@ func HandleMessage(bot *tgbotapi.BotAPI, store *storage.Storage, message *tgbota
 			Reply(bot, chatId, "Пример: \n /timezone Europe/Moscow \n /timezone Asia/Krasnoyarsk ")
 			return
 		}
-		if err := store.ChatSettings().UpsertTZ(context.Background(), chatId, timezone); err != nil {
+		if err := store.ChatSettings().UpsertTZ(ctx, chatId, timezone); err != nil {
 			Reply(bot, chatId, "Не смог сохранить timezone")
 		} else {
 			Reply(bot, chatId, "Часовой пояс обновлён: "+timezone)
@@ -73,7 +86,7 @@ func HandleMessage(bot *tgbotapi.BotAPI, store *storage.Storage, message *tgbota
 	case strings.HasPrefix(text, "/report"):
 		arg := strings.TrimSpace(strings.TrimPrefix(text, "/report"))
 		if strings.ToLower(arg) == "off" {
-			_ = store.ChatSettings().UpsertDigest(context.Background(), chatId, nil)
+			_ = store.ChatSettings().UpsertDigest(ctx, chatId, nil)
 			Reply(bot, chatId, "Ежевечерний отчёт выключен")
 			return
 		}
@@ -82,26 +95,59 @@ func HandleMessage(bot *tgbotapi.BotAPI, store *storage.Storage, message *tgbota
 			Reply(bot, chatId, "Пример:\n /report 20:00 (Ежевечерний отчёт будет приходить в указанное время, обязательно указывать в формате HH:MM)\n /report off (Выключение ежевечернего отчёта)")
 			return
 		}
-		_ = store.ChatSettings().UpsertDigest(context.Background(), chatId, &t)
+		_ = store.ChatSettings().UpsertDigest(ctx, chatId, &t)
 		Reply(bot, chatId, "Ок, буду слать отчёт в "+arg)
 
+	case strings.HasPrefix(text, "/quiet"):
+		HandleQuiet(ctx, bot, store, chatId, strings.TrimSpace(strings.TrimPrefix(text, "/quiet")))
+
 	case strings.HasPrefix(text, "/list"):
 		arg := strings.TrimSpace(strings.TrimPrefix(text, "/list"))
 		if arg == "" {
 			arg = "today"
 		}
-		HandleList(bot, store, chatId, arg)
+		HandleList(ctx, bot, store, chatId, arg)
+
+	case strings.HasPrefix(text, "/find"):
+		HandleFind(ctx, bot, store, chatId, strings.TrimPrefix(text, "/find"))
+
+	case strings.HasPrefix(text, "/history"):
+		HandleHistory(ctx, bot, store, chatId, strings.TrimPrefix(text, "/history"))
 
 	case strings.HasPrefix(text, "/timetable"):
 		rest := strings.TrimSpace(strings.TrimPrefix(text, "/timetable"))
-		HandleTimetable(bot, store, chatId, rest)
+		var owner *tgbotapi.User
+		if isGroup(message.Chat) {
+			owner = message.From
+		}
+		HandleTimetable(ctx, bot, store, chatId, owner, rest)
+
+	case strings.HasPrefix(text, "/calendar"):
+		HandleCalendar(ctx, bot, store, cfg.SelfURL, chatId, strings.TrimPrefix(text, "/calendar"))
+
+	case strings.HasPrefix(text, "/token"):
+		HandleToken(ctx, bot, store, cfg.SelfURL, message, strings.TrimPrefix(text, "/token"))
+
+	case strings.HasPrefix(text, "/export"):
+		HandleExport(ctx, bot, store, message, strings.TrimPrefix(text, "/export"))
+
+	case strings.HasPrefix(text, "/import"):
+		Reply(bot, chatId, "Пришли файл выгрузки .json (его делает /export) — покажу, что будет восстановлено, и попрошу подтвердить")
+
+	case strings.HasPrefix(text, "/forget"):
+		HandleForget(ctx, bot, store, message)
+
+	case strings.HasPrefix(text, "/jobs"):
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "/jobs"))
+		HandleJobs(ctx, bot, store, cfg, chatId, rest)
+
 	default:
-		HandleNaturalReminder(bot, store, message)
+		HandleNaturalReminder(ctx, bot, store, message, text)
 	}
 }
 
-func HandleList(bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func HandleList(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	cs, _ := store.ChatSettings().Get(ctx, chatID)
@@ -139,7 +185,8 @@ func HandleList(bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg
 		return
 	}
 
-	log.Printf("[/list] chat=%d tz=%s arg=%s from=%v to=%v", chatID, tz, arg, fromUTC, toUTC)
+	logger := logging.FromContext(ctx)
+	logger.Debug("list reminders", "tz", tz, "arg", arg, "from", fromUTC, "to", toUTC)
 
 	if fromUTC == nil {
 		Reply(bot, chatID, "Не смог определить диапазон /list")
@@ -149,10 +196,10 @@ func HandleList(bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg
 	items, err := store.Reminders().GetUpcoming(ctx, chatID, *fromUTC, toUTC, 50)
 	if err != nil {
 		Reply(bot, chatID, "Не удалось получить список")
-		log.Printf("[/list] GetUpcoming error: %v", err)
+		logger.Error("list reminders failed", logging.Err(err))
 		return
 	}
-	log.Printf("[/list] items=%d", len(items))
+	logger.Debug("list reminders done", "items", len(items))
 
 	if len(items) == 0 {
 		Reply(bot, chatID, "Пусто в выбранном диапазоне")
@@ -167,83 +214,304 @@ func HandleList(bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg
 		} else if r.NextReport != nil {
 			when = r.NextReport.In(loc).Format("Mon, 02 Jan 15:04")
 		}
-		fmt.Fprintf(&b, "• %s — %s\n", when, r.Message)
+		fmt.Fprintf(&b, "• %s — %s", when, r.Message)
+		switch {
+		case r.Broadcast:
+			b.WriteString(" (всем)")
+		case r.AssigneeName != "":
+			fmt.Fprintf(&b, " (→ %s)", r.AssigneeName)
+		case chatID < 0 && r.AuthorName != "":
+			fmt.Fprintf(&b, " (%s)", r.AuthorName)
+		}
+		b.WriteString("\n")
 	}
 	Reply(bot, chatID, b.String())
 }
 
-func HandleTimetable(bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, rest string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+// HandleTimetable — недельное расписание. В группе owner — автор команды:
+// set и clear меняют только его записи, show и free учитывают всех.
+func HandleTimetable(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, owner *tgbotapi.User, rest string) {
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
 
+	const usage = "Использование:\n/timetable show [next | all]\n/timetable add Вт,Чт 19:00 Спорт\n/timetable set Пн-Пт 10-18 Работа — заменить дни\n" +
+		"/timetable add Пн числ 9-10:30 Матан с 01.09 по 28.12 @ ауд. 301 // конспект\n" +
+		"/timetable remove Ср #1 | Ср 9:00\n/timetable move Ср #1 Пт [18-19]\n/timetable free Пн [09:00-18:00]\n/timetable clear\n" +
+		"/timetable import + таблица с новой строки (или пришли файл .csv) — заменить всё расписание\n" +
+		"Разовые изменения:\n/timetable cancel 04.11 Работа\n/timetable once 05.11 10-12 Пересдача\n" +
+		"/timetable move 04.11 Работа 06.11 [10-18]\n/timetable exceptions | restore #id\n/timetable holidays on | all | off"
 	parts := strings.Fields(rest)
 	if len(parts) == 0 {
-		Reply(bot, chatID, "Использование:\n/timetable show\n/timetable clear\n/timetable set Пн 10-18 Работа")
+		Reply(bot, chatID, usage)
 		return
 	}
 	sub := strings.ToLower(parts[0])
+	ownerID, ownerName := timetableOwner(owner)
+	cs, _ := store.ChatSettings().Get(ctx, chatID)
+	now := time.Now().In(storage.LoadUserLocation(cs.TimeZone))
 
 	switch sub {
 	case "show", "показать":
+		// без аргумента — текущая неделя с учётом чередования и периодов,
+		// next — следующая, all — все записи как есть
+		mode := ""
+		if len(parts) > 1 {
+			mode = strings.ToLower(parts[1])
+		}
+		monday := mondayOf(now)
+		all := mode == "all" || mode == "все"
+		if mode == "next" || mode == "след" {
+			monday = monday.AddDate(0, 0, 7)
+		}
 		var b strings.Builder
-		names := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+		if !all {
+			fmt.Fprintf(&b, "Неделя %s–%s:\n", monday.Format("02.01"), monday.AddDate(0, 0, 6).Format("02.01"))
+		}
+		header := b.Len()
+		var exs []storage.ScheduleException
+		if !all {
+			var err error
+			exs, err = store.Exceptions().Between(ctx, chatID, monday, monday.AddDate(0, 0, 6))
+			if err != nil {
+				Reply(bot, chatID, "Ошибка чтения расписания")
+				return
+			}
+		}
 		for wd := 1; wd <= 7; wd++ {
 			entries, err := store.Schedule().ListForWeekday(ctx, chatID, wd)
 			if err != nil {
 				Reply(bot, chatID, "Ошибка чтения расписания")
 				return
 			}
-			if len(entries) == 0 {
+			day := monday.AddDate(0, 0, wd-1)
+			holiday := !all && holidaysOn(cs) && holidays.DayOff(day)
+			var lines []string
+			// номера — по всем записям дня, чтобы совпадать с remove и move
+			for i, e := range entries {
+				switch {
+				case all:
+				case !e.ActiveOn(day) || holiday:
+					continue
+				case cancelled(e, exs, day):
+					lines = append(lines, fmt.Sprintf("#%d ✖️ отменено: %s", i+1, describeEntry(e)))
+					continue
+				}
+				lines = append(lines, fmt.Sprintf("#%d %s", i+1, describeEntry(e)))
+			}
+			for _, x := range addedOn(exs, day) {
+				lines = append(lines, "➕ "+describeEntry(x.Entry())+" — разово")
+			}
+			if len(lines) == 0 && !holiday {
 				continue
 			}
-			fmt.Fprintf(&b, "%s:\n", names[wd-1])
-			for _, e := range entries {
-				st := e.StartTime.Format("15:04")
-				et := ""
-				if e.EndTime != nil {
-					et = "–" + e.EndTime.Format("15:04")
-				}
-				fmt.Fprintf(&b, "  %s%s — %s\n", st, et, e.Title)
+			switch {
+			case all:
+				fmt.Fprintf(&b, "%s:\n", weekdayShort[wd-1])
+			case holiday:
+				fmt.Fprintf(&b, "%s %s — праздник, занятий нет\n", weekdayShort[wd-1], day.Format("02.01"))
+			default:
+				fmt.Fprintf(&b, "%s %s:\n", weekdayShort[wd-1], day.Format("02.01"))
+			}
+			for _, l := range lines {
+				b.WriteString("  " + l + "\n")
+			}
+		}
+		if b.Len() == header {
+			if all {
+				Reply(bot, chatID, "Расписание пусто")
+			} else {
+				Reply(bot, chatID, b.String()+"— занятий нет (все записи: /timetable show all)")
 			}
+			return
+		}
+		Reply(bot, chatID, b.String())
+
+	case "free", "свободно":
+		wd, window, err := parseFreeArgs(parts[1:])
+		if err != nil {
+			Reply(bot, chatID, "Пример: /timetable free Пн или /timetable free Пн 10-18")
+			return
+		}
+		day := nextWeekday(now, wd)
+		entries, err := loadDay(ctx, store, cs, chatID, day)
+		if err != nil {
+			Reply(bot, chatID, "Ошибка чтения расписания")
+			return
 		}
-		if b.Len() == 0 {
-			Reply(bot, chatID, "Расписание пусто")
+		dayName := weekdayShort[wd-1] + " " + day.Format("02.01")
+		slots := freeSlots(entries, window)
+		if len(slots) == 0 {
+			Reply(bot, chatID, fmt.Sprintf("%s %s: общего свободного времени нет", dayName, window))
 			return
 		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s, свободно у всех в %s:\n", dayName, window)
+		for _, sl := range slots {
+			fmt.Fprintf(&b, "  %s\n", sl)
+		}
+		if len(entries) > 0 {
+			fmt.Fprintf(&b, "Учтено расписание: %s", ownersBusy(entries))
+		}
 		Reply(bot, chatID, b.String())
 
 	case "clear", "очистить":
-		if err := store.Schedule().Clear(ctx, chatID); err != nil {
+		if err := store.Schedule().Clear(ctx, chatID, ownerID); err != nil {
 			Reply(bot, chatID, "Не удалось очистить")
 			return
 		}
+		if ownerID != nil {
+			Reply(bot, chatID, "Твоё расписание очищено")
+			return
+		}
 		Reply(bot, chatID, "Расписание очищено")
 
-	case "set", "задать":
+	case "set", "задать", "replace", "заменить", "add", "добавить":
+		example := "/timetable " + sub + " Пн 10-18 Работа"
 		raw := strings.TrimSpace(strings.TrimPrefix(rest, parts[0]))
 		if raw == "" {
-			Reply(bot, chatID, "Пример: /timetable set Пн 10-18 Работа")
+			Reply(bot, chatID, "Пример: "+example)
 			return
 		}
 		entries, err := timeparse.ParseWeeklyEntries(raw)
 		if err != nil {
-			Reply(bot, chatID, "Не понял формат. Пример: /timetable set Пн 10-18 Работа")
+			Reply(bot, chatID, "Не понял формат ("+err.Error()+"). Пример: "+example)
+			return
+		}
+		if len(entries) == 0 {
+			Reply(bot, chatID, "Пример: "+example)
 			return
 		}
-		if err := store.Schedule().Set(ctx, chatID, entries); err != nil {
+		for i := range entries {
+			entries[i].OwnerName = ownerName
+		}
+		if sub == "add" || sub == "добавить" {
+			if err := store.Schedule().Add(ctx, chatID, ownerID, entries); err != nil {
+				Reply(bot, chatID, "Не удалось сохранить расписание")
+				return
+			}
+			Reply(bot, chatID, fmt.Sprintf("Добавлено записей: %d", len(entries)))
+			return
+		}
+		// set заменяет только упомянутые дни, а не всю неделю
+		if err := store.Schedule().ReplaceDays(ctx, chatID, ownerID, entries); err != nil {
 			Reply(bot, chatID, "Не удалось сохранить расписание")
 			return
 		}
-		Reply(bot, chatID, "Расписание обновлено")
+		if ownerID != nil {
+			Reply(bot, chatID, "Твоё расписание обновлено: "+weekdaysOf(entries))
+			return
+		}
+		Reply(bot, chatID, "Расписание обновлено: "+weekdaysOf(entries))
+
+	case "import", "импорт":
+		// таблица идёт после команды, обычно с новой строки
+		raw := strings.TrimSpace(strings.TrimPrefix(rest, parts[0]))
+		if raw == "" {
+			Reply(bot, chatID, "Пришли таблицу после команды, по строке на занятие:\n"+
+				"/timetable import\nдень;начало;конец;название;место;неделя\nПн;9:00;10:30;Матан;301;числ\nВт-Чт;19:00;;Спорт\n"+
+				"Можно вставить строки прямо из электронной таблицы или прислать файл .csv")
+			return
+		}
+		previewTimetableImport(ctx, bot, store, chatID, owner, []byte(raw))
+
+	case "cancel", "отменить", "once", "разово", "exceptions", "исключения", "restore", "вернуть":
+		handleTimetableException(ctx, bot, store, chatID, now, ownerID, ownerName, sub, parts[1:])
+
+	case "holidays", "праздники":
+		handleHolidays(ctx, bot, store, chatID, cs, parts[1:])
+
+	case "remove", "удалить", "move", "перенести":
+		move := sub == "move" || sub == "перенести"
+		if _, isDate := parseDayArg(parts[1:], now); move && isDate {
+			handleTimetableException(ctx, bot, store, chatID, now, ownerID, ownerName, "move", parts[1:])
+			return
+		}
+		if len(parts) < 3 || (move && len(parts) < 4) {
+			Reply(bot, chatID, "Пример: /timetable remove Ср #1 или /timetable move Ср #1 Пт 18-19")
+			return
+		}
+		wd, ok := timeparse.ParseWeekday(parts[1])
+		if !ok {
+			Reply(bot, chatID, "Не понял день недели: "+parts[1])
+			return
+		}
+		day, err := store.Schedule().ListForWeekday(ctx, chatID, wd)
+		if err != nil {
+			Reply(bot, chatID, "Ошибка чтения расписания")
+			return
+		}
+		found, err := selectEntries(day, parts[2])
+		if err != nil {
+			Reply(bot, chatID, "Нет такой записи, посмотри номера в /timetable show")
+			return
+		}
+		var own []storage.WeeklyEntry
+		for _, e := range found {
+			if sameOwner(e.UserID, ownerID) {
+				own = append(own, e)
+			}
+		}
+		if len(own) == 0 {
+			Reply(bot, chatID, "Это запись другого участника")
+			return
+		}
+
+		if !move {
+			removed := 0
+			for _, e := range own {
+				ok, err := store.Schedule().Delete(ctx, chatID, ownerID, e.ID)
+				if err != nil {
+					logging.FromContext(ctx).Error("timetable delete failed", "entry_id", e.ID, logging.Err(err))
+					Reply(bot, chatID, "Не удалось удалить запись")
+					return
+				}
+				if ok {
+					removed++
+				}
+			}
+			Reply(bot, chatID, fmt.Sprintf("Удалено записей: %d", removed))
+			return
+		}
+
+		if len(own) > 1 {
+			Reply(bot, chatID, "В это время несколько записей, укажи номер: /timetable move Ср #1 Пт")
+			return
+		}
+		e := own[0]
+		to, ok := timeparse.ParseWeekday(parts[3])
+		if !ok {
+			Reply(bot, chatID, "Не понял день недели: "+parts[3])
+			return
+		}
+		start, end := e.StartTime, e.EndTime
+		if len(parts) > 4 {
+			st, et, err := timeparse.ParseTimeRange(parts[4])
+			if err != nil {
+				Reply(bot, chatID, "Не понял время: "+parts[4])
+				return
+			}
+			// без нового конца сохраняем прежнюю длительность
+			if et == nil && e.EndTime != nil {
+				t := st.Add(entryDuration(e))
+				et = &t
+			}
+			start, end = st, et
+		}
+		if _, err := store.Schedule().Move(ctx, chatID, ownerID, e.ID, to, start, end); err != nil {
+			logging.FromContext(ctx).Error("timetable move failed", "entry_id", e.ID, logging.Err(err))
+			Reply(bot, chatID, "Не удалось перенести запись")
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("«%s» перенесено на %s %s", e.Title, weekdayShort[to-1], start.Format("15:04")))
 
 	default:
-		Reply(bot, chatID, "Неизвестная подкоманда. Использование:\n/timetable show | clear | set ...")
+		Reply(bot, chatID, "Неизвестная подкоманда. "+usage)
 	}
 }
 
-func HandleNaturalReminder(bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message) {
+func HandleNaturalReminder(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message, text string) {
 	chatID := m.Chat.ID
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	cs, _ := store.ChatSettings().Get(ctx, chatID)
 	tz := cs.TimeZone
@@ -251,48 +519,165 @@ func HandleNaturalReminder(bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbo
 		tz = "UTC"
 	}
 
-	p, err := timeparse.ParseRU(m.Text, tz, time.Now())
+	text, broadcast := cutBroadcast(m, text)
+	var assignee *storage.KnownUser
+	if isGroup(m.Chat) && !broadcast {
+		u, rest, mentioned, err := leadingAssignee(ctx, bot, store, m, text)
+		if errors.Is(err, errNotMember) {
+			Reply(bot, chatID, "Назначить напоминание можно только участнику этой группы")
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("resolve assignee failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось найти участника, попробуй ещё раз")
+			return
+		}
+		if mentioned && u == nil {
+			Reply(bot, chatID, "Не знаю такого участника. Пусть он напишет что-нибудь в группе или запустит меня в личке — тогда смогу назначать ему напоминания.")
+			return
+		}
+		assignee, text = u, rest
+	}
+	p, err := timeparse.ParseRU(text, tz, time.Now())
 	if err != nil {
-		Reply(bot, chatID, "Не понял дату/время \nПримеры:\n• 25 сентября 14:00 встреча за 1 час \n• во вторник 18:00 спортзал за 2 часа \n• /add 2025-09-30 14:00 Встреча")
+		metrics.ParseResults.WithLabelValues("error").Inc()
+		Reply(bot, chatID, "Не понял дату/время \nПримеры:\n• 25 сентября 14:00 встреча за 1 час \n• во вторник 18:00 спортзал за 2 часа \n• завтра 10:00 экзамен за день и за 15 минут \n• сегодня 21:00 таблетки настойчиво \n• /add 2025-09-30 14:00 Встреча")
 		return
 	}
 
+	metrics.ParseResults.WithLabelValues("ok").Inc()
+
+	rem := &storage.Reminder{
+		ChatID:          chatID,
+		Message:         p.Title,
+		ReminderOffsets: p.LeadOffsets,
+		Persistent:      p.Persistent,
+		Broadcast:       broadcast,
+	}
+	if m.From != nil {
+		rem.UserID = &m.From.ID
+		rem.AuthorName = authorName(m.From)
+	}
+	if assignee != nil {
+		rem.AssigneeID = &assignee.ID
+		rem.AssigneeName = assignee.DisplayName()
+	}
+	if p.Persistent {
+		rem.NagInterval = p.NagEvery
+		if rem.NagInterval <= 0 {
+			rem.NagInterval = storage.DefaultNagInterval
+		}
+		rem.NagMax = storage.DefaultNagMax
+	}
+	loc := storage.LoadUserLocation(tz)
+
 	if p.DueUTC != nil {
-		id, err := store.Reminders().AddReminder(ctx, chatID, p.Title, p.DueUTC.UTC(), p.LeadMinutes)
+		due := p.DueUTC.UTC()
+		rem.EventTime = &due
+		conflicts, busy, err := findConflicts(ctx, store, cs, *rem, loc)
 		if err != nil {
-			Reply(bot, chatID, "Не смог сохранить напоминание ")
+			// проверка — подсказка, без неё напоминание всё равно создаём
+			logging.FromContext(ctx).Warn("conflict check failed", logging.Err(err))
+		}
+		if len(conflicts) > 0 {
+			askConflict(ctx, bot, *rem, loc, conflicts, busy)
 			return
 		}
-		fire := p.DueUTC.Add(-time.Duration(p.LeadMinutes) * time.Minute)
-		if fire.Before(time.Now().UTC()) {
-			fire = time.Now().UTC()
+		text, err := saveOneOff(ctx, store, rem, loc)
+		if err != nil {
+			Reply(bot, chatID, "Не смог сохранить напоминание ")
+			return
 		}
-		_ = store.Jobs().Create(ctx, id, fire)
-
-		loc := storage.LoadUserLocation(tz)
-		Reply(bot, chatID, fmt.Sprintf("Ок! Напомню %s — %s",
-			p.DueUTC.In(loc).Format("Mon, 02 Jan 15:04"), p.Title))
+		Reply(bot, chatID, text)
 		return
 	}
 
 	if p.RRULE != nil {
 		next := storage.NextFromWeeklyRRULE(*p.RRULE, tz, time.Now())
-		id, err := store.Reminders().AddRecurring(ctx, chatID, p.Title, p.LeadMinutes, *p.RRULE, next)
+		rem.ReminderRule = p.RRULE
+		rem.NextReport = &next
+
+		// Для серии проверяется только ближайшее срабатывание и без кнопок:
+		// перенос одного срабатывания серия не поддерживает. Проверка до
+		// Create, чтобы серия не нашла пересечение сама с собой.
+		first := *rem
+		first.EventTime = &next
+		conflicts, _, err := findConflicts(ctx, store, cs, first, loc)
+		if err != nil {
+			logging.FromContext(ctx).Warn("conflict check failed", logging.Err(err))
+		}
+
+		id, err := store.Reminders().Create(ctx, rem)
 		if err != nil {
 			Reply(bot, chatID, "Не смог сохранить повторяющееся напоминание ")
 			return
 		}
-		fire := next.Add(-time.Duration(p.LeadMinutes) * time.Minute)
-		if fire.Before(time.Now().UTC()) {
-			fire = time.Now().UTC()
-		}
-		_ = store.Jobs().Create(ctx, id, fire)
+		_ = store.Jobs().CreateForEvent(ctx, id, next, p.LeadOffsets, time.Now().UTC())
 
-		loc := storage.LoadUserLocation(tz)
-		Reply(bot, chatID, fmt.Sprintf("Ок! Каждую неделю. Ближайшее: %s — %s",
-			next.In(loc).Format("Mon, 02 Jan 15:04"), p.Title))
+		reply := fmt.Sprintf("Ок! Каждую неделю. Ближайшее: %s — %s (%s)",
+			next.In(loc).Format("Mon, 02 Jan 15:04"), p.Title, reminderModes(rem))
+		if len(conflicts) > 0 {
+			reply += "\n\n⚠️ Ближайшее срабатывание пересекается с:\n" + strings.Join(conflicts, "\n") +
+				"\nОстальные недели не проверял."
+		}
+		Reply(bot, chatID, reply)
 		return
 	}
 
 	Reply(bot, chatID, "Кажется, я не распознал формат. Пример: «25 сентября 14:00 встреча»")
 }
+
+// saveOneOff сохраняет разовое напоминание с заполненным EventTime, ставит
+// задания и возвращает текст подтверждения.
+func saveOneOff(ctx context.Context, store *storage.Storage, rem *storage.Reminder, loc *time.Location) (string, error) {
+	due := *rem.EventTime
+	id, err := store.Reminders().Create(ctx, rem)
+	if err != nil {
+		return "", err
+	}
+	_ = store.Jobs().CreateForEvent(ctx, id, due, rem.ReminderOffsets, time.Now().UTC())
+	return fmt.Sprintf("Ок! Напомню %s — %s (%s)",
+		due.In(loc).Format("Mon, 02 Jan 15:04"), rem.Message, reminderModes(rem)), nil
+}
+
+// reminderModes — смещения и режим повтора для ответа о сохранении.
+func reminderModes(r *storage.Reminder) string {
+	s := formatLeads(r.ReminderOffsets)
+	if r.Persistent {
+		s += fmt.Sprintf("; настойчиво, каждые %s до «Готово»", formatLead(r.NagInterval))
+	}
+	if r.Broadcast {
+		s += "; для всех"
+	}
+	if r.AssigneeName != "" {
+		s += "; исполнитель: " + r.AssigneeName
+	}
+	return s
+}
+
+// formatLeads — «за 1 д, за 15 мин, в момент начала».
+func formatLeads(offsets []int) string {
+	parts := make([]string, 0, len(offsets))
+	for _, o := range offsets {
+		if o == 0 {
+			parts = append(parts, "в момент начала")
+			continue
+		}
+		parts = append(parts, "за "+formatLead(o))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatLead(min int) string {
+	var parts []string
+	for _, u := range []struct {
+		size int
+		name string
+	}{{7 * 24 * 60, "нед"}, {24 * 60, "д"}, {60, "ч"}, {1, "мин"}} {
+		if min >= u.size {
+			parts = append(parts, fmt.Sprintf("%d %s", min/u.size, u.name))
+			min %= u.size
+		}
+	}
+	return strings.Join(parts, " ")
+}
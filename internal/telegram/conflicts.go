@@ -0,0 +1,255 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	conflictTTL = 30 * time.Minute
+	// eventLength — сколько считать занятым после начала напоминания:
+	// длительности у напоминаний нет.
+	eventLength = 60
+	// suggestMax — сколько свободных слотов предлагать кнопками.
+	suggestMax = 3
+)
+
+// suggestWindow — в какие часы искать свободное время для переноса.
+var suggestWindow = interval{8 * 60, 22 * 60}
+
+// pendingReminder — напоминание, пересекающееся с расписанием, ждёт
+// подтверждения или выбора другого времени. Ответить может только его
+// автор (rem.UserID).
+type pendingReminder struct {
+	rem storage.Reminder
+	loc *time.Location
+}
+
+var pendingReminders = newPendingSet[*pendingReminder](conflictTTL)
+
+// personOf — чьё время занимает напоминание: исполнителя или автора.
+func personOf(r storage.Reminder) *int64 {
+	if r.AssigneeID != nil {
+		return r.AssigneeID
+	}
+	return r.UserID
+}
+
+// findConflicts ищет записи расписания и другие напоминания чата, которые
+// пересекаются с разовым напоминанием rem. В группе учитываются общие
+// записи и записи того, кому адресовано напоминание (для «всем» — все).
+// busy — занятые интервалы того же дня, для подбора свободного времени.
+func findConflicts(ctx context.Context, store *storage.Storage, cs storage.ChatSettings, rem storage.Reminder, loc *time.Location) (conflicts []string, busy []interval, err error) {
+	local := rem.EventTime.In(loc)
+	ev := interval{minutesOf(local), min(minutesOf(local)+eventLength, 24*60)}
+	person := personOf(rem)
+
+	wd := storage.ISOWeekday(local)
+	entries, err := loadDay(ctx, store, cs, rem.ChatID, local)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if !rem.Broadcast && e.UserID != nil && !sameOwner(e.UserID, person) {
+			continue
+		}
+		iv := busyInterval(e)
+		busy = append(busy, iv)
+		if overlaps(iv, ev) {
+			line := fmt.Sprintf("📅 %s %s — %s", weekdayShort[wd-1], iv, e.Title)
+			if e.OwnerName != "" {
+				line += " (" + e.OwnerName + ")"
+			}
+			conflicts = append(conflicts, line)
+		}
+	}
+
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	reminders, err := store.Reminders().GetUpcoming(ctx, rem.ChatID, dayStart.UTC(), &dayEnd, 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range reminders {
+		t := r.EventTime
+		if t == nil {
+			t = r.NextReport
+		}
+		if t == nil || !t.Before(dayEnd) {
+			continue
+		}
+		// напоминание без автора — общее для чата, как запись расписания без владельца
+		if owner := personOf(r); !rem.Broadcast && !r.Broadcast && owner != nil && !sameOwner(owner, person) {
+			continue
+		}
+		at := t.In(loc)
+		iv := interval{minutesOf(at), min(minutesOf(at)+eventLength, 24*60)}
+		busy = append(busy, iv)
+		if overlaps(iv, ev) {
+			conflicts = append(conflicts, fmt.Sprintf("⏰ %s — %s", at.Format("15:04"), r.Message))
+		}
+	}
+	return conflicts, busy, nil
+}
+
+func overlaps(a, b interval) bool { return a.from < b.to && b.from < a.to }
+
+// suggestSlots подбирает до suggestMax свободных начал длиной eventLength,
+// ближайших к исходному времени и не раньше notBefore (минуты дня).
+func suggestSlots(busy []interval, want, notBefore int) []int {
+	var cands []int
+	for _, g := range gaps(busy, suggestWindow) {
+		if g.from < notBefore {
+			g.from = notBefore
+		}
+		if g.to-g.from < eventLength {
+			continue
+		}
+		// ближайшее к желаемому время внутри промежутка
+		c := min(max(want, g.from), g.to-eventLength)
+		cands = append(cands, c)
+		for _, edge := range []int{g.from, g.to - eventLength} {
+			if edge != c {
+				cands = append(cands, edge)
+			}
+		}
+	}
+	dist := func(m int) int {
+		if m < want {
+			return want - m
+		}
+		return m - want
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return dist(cands[i]) < dist(cands[j]) })
+
+	var out []int
+	seen := map[int]bool{}
+	for _, c := range cands {
+		if !seen[c] && len(out) < suggestMax {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// askConflict сообщает о пересечениях и предлагает создать напоминание как
+// есть, перенести его на свободное время или отменить.
+func askConflict(ctx context.Context, bot *tgbotapi.BotAPI, rem storage.Reminder, loc *time.Location, conflicts []string, busy []interval) {
+	local := rem.EventTime.In(loc)
+	notBefore := 0
+	if now := time.Now().In(loc); sameDay(now, local) {
+		notBefore = minutesOf(now) + 1
+	}
+	slots := suggestSlots(busy, minutesOf(local), notBefore)
+
+	nonce := pendingReminders.put(rem.ChatID, &pendingReminder{rem: rem, loc: loc})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ %s — %s пересекается с:\n", local.Format("Mon, 02 Jan 15:04"), rem.Message)
+	for _, c := range conflicts {
+		b.WriteString(c + "\n")
+	}
+	if len(slots) > 0 {
+		b.WriteString("\nМожно перенести на свободное время.")
+	} else {
+		b.WriteString("\nСвободного времени в этот день не нашёл.")
+	}
+
+	var slotRow []tgbotapi.InlineKeyboardButton
+	for _, s := range slots {
+		slotRow = append(slotRow, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("🕒 %02d:%02d", s/60, s%60), fmt.Sprintf("rc:at:%s:%d", nonce, s)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(slotRow) > 0 {
+		rows = append(rows, slotRow)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Всё равно создать", fmt.Sprintf("rc:ok:%s", nonce)),
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", fmt.Sprintf("rc:cancel:%s", nonce)),
+	))
+
+	msg := tgbotapi.NewMessage(rem.ChatID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("conflict prompt send failed", logging.Err(err))
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// handleConflictCallback: "ok:<nonce>", "at:<nonce>:<минуты дня>" или
+// "cancel:<nonce>".
+func handleConflictCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	parts := strings.Split(arg, ":")
+	if len(parts) < 2 {
+		answerCallback(ctx, bot, cq.ID, "")
+		return
+	}
+	action, nonce := parts[0], parts[1]
+	// слот проверяется до того, как запись забрана: с битыми данными
+	// кнопки вопрос должен остаться в силе
+	slot := -1
+	if action == "at" && len(parts) == 3 {
+		m, err := strconv.Atoi(parts[2])
+		if err != nil || m < 0 || m >= 24*60 {
+			answerCallback(ctx, bot, cq.ID, "")
+			return
+		}
+		slot = m
+	}
+
+	p, ok := pendingReminders.get(chatID, nonce)
+	if ok && p.rem.UserID != nil && (cq.From == nil || cq.From.ID != *p.rem.UserID) {
+		answerCallback(ctx, bot, cq.ID, "Ответить может только автор напоминания")
+		return
+	}
+	if !ok || !pendingReminders.remove(nonce) {
+		answerCallback(ctx, bot, cq.ID, "Запрос устарел, напиши напоминание ещё раз")
+		return
+	}
+
+	var result string
+	switch action {
+	case "ok", "at":
+		rem := p.rem
+		if slot >= 0 {
+			local := rem.EventTime.In(p.loc)
+			due := time.Date(local.Year(), local.Month(), local.Day(), slot/60, slot%60, 0, 0, p.loc).UTC()
+			rem.EventTime = &due
+		}
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		text, err := saveOneOff(ctx, store, &rem, p.loc)
+		if err != nil {
+			logging.FromContext(ctx).Error("create reminder failed", logging.Err(err))
+			answerCallback(ctx, bot, cq.ID, "Не удалось сохранить")
+			return
+		}
+		answerCallback(ctx, bot, cq.ID, "Сохранено")
+		result = text
+	default:
+		answerCallback(ctx, bot, cq.ID, "Отменено")
+		result = "Напоминание не создано"
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,279 @@
+package telegram
+
+import (
+	"TelegramBot/internal/holidays"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// parseDayArg разбирает дату в args[0]: 2026-11-04, 04.11, 04.11.2026,
+// «сегодня», «завтра». Дата без года, которая в этом году уже прошла,
+// относится к следующему. Возвращает полночь в зоне now.
+func parseDayArg(args []string, now time.Time) (time.Time, bool) {
+	if len(args) == 0 {
+		return time.Time{}, false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	s := strings.ToLower(args[0])
+	switch s {
+	case "сегодня", "today":
+		return today, true
+	case "завтра", "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	for _, layout := range []string{time.DateOnly, "02.01.2006", "2.1.2006", "02.01.06"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location()), true
+		}
+	}
+	for _, layout := range []string{"02.01", "2.1"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			d := time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+			if d.Before(today) {
+				d = d.AddDate(1, 0, 0)
+			}
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// findOwnEntries ищет записи владельца, идущие в день day, по названию или
+// по времени начала. byTime — выбор был по времени.
+func findOwnEntries(ctx context.Context, store *storage.Storage, chatID int64, ownerID *int64, day time.Time, sel string) ([]storage.WeeklyEntry, bool, error) {
+	entries, err := store.Schedule().ListForWeekday(ctx, chatID, storage.ISOWeekday(day))
+	if err != nil {
+		return nil, false, err
+	}
+	at, timeErr := timeparse.ParseHM(sel)
+	var out []storage.WeeklyEntry
+	for _, e := range activeOn(entries, day) {
+		if !sameOwner(e.UserID, ownerID) {
+			continue
+		}
+		if timeErr == nil && minutesOf(e.StartTime) == minutesOf(at) ||
+			timeErr != nil && strings.EqualFold(e.Title, sel) {
+			out = append(out, e)
+		}
+	}
+	return out, timeErr == nil, nil
+}
+
+// handleTimetableException — разовые изменения расписания: cancel, once,
+// move на дату, exceptions и restore.
+func handleTimetableException(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, now time.Time,
+	ownerID *int64, ownerName, sub string, args []string) {
+	dayName := func(d time.Time) string { return weekdayShort[storage.ISOWeekday(d)-1] + " " + d.Format("02.01") }
+
+	switch sub {
+	case "cancel", "отменить":
+		day, ok := parseDayArg(args, now)
+		if !ok || len(args) < 2 {
+			Reply(bot, chatID, "Пример: /timetable cancel 04.11 Работа или /timetable cancel 2026-11-04 10:00")
+			return
+		}
+		found, byTime, err := findOwnEntries(ctx, store, chatID, ownerID, day, strings.Join(args[1:], " "))
+		if err != nil {
+			Reply(bot, chatID, "Ошибка чтения расписания")
+			return
+		}
+		if len(found) == 0 {
+			Reply(bot, chatID, "В "+dayName(day)+" нет такой записи в твоём расписании")
+			return
+		}
+		x := storage.ScheduleException{ChatID: chatID, UserID: ownerID, OwnerName: ownerName,
+			Date: day, Kind: storage.ExceptionCancel, Title: found[0].Title}
+		if byTime {
+			x.StartTime = &found[0].StartTime
+		}
+		if err := store.Exceptions().Add(ctx, x); err != nil {
+			logging.FromContext(ctx).Error("add schedule exception failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось сохранить")
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("Отменено: %s — %s", dayName(day), found[0].Title))
+
+	case "once", "разово":
+		day, ok := parseDayArg(args, now)
+		if !ok || len(args) < 3 {
+			Reply(bot, chatID, "Пример: /timetable once 05.11 10-12 Пересдача")
+			return
+		}
+		st, et, err := timeparse.ParseTimeRange(args[1])
+		if err != nil {
+			Reply(bot, chatID, "Не понял время: "+args[1])
+			return
+		}
+		x := storage.ScheduleException{ChatID: chatID, UserID: ownerID, OwnerName: ownerName, Date: day,
+			Kind: storage.ExceptionAdd, StartTime: &st, EndTime: et, Title: strings.Join(args[2:], " ")}
+		if err := store.Exceptions().Add(ctx, x); err != nil {
+			logging.FromContext(ctx).Error("add schedule exception failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось сохранить")
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("Добавлено разово: %s %s", dayName(day), describeEntry(x.Entry())))
+
+	case "move":
+		// <дата> <название|время> <новая дата> [время]
+		usage := "Пример: /timetable move 04.11 Работа 06.11 или /timetable move 04.11 10:00 06.11 12-14"
+		day, ok := parseDayArg(args, now)
+		if !ok {
+			Reply(bot, chatID, usage)
+			return
+		}
+		split := -1
+		var to time.Time
+		for k := 2; k < len(args); k++ {
+			if d, ok := parseDayArg(args[k:], now); ok {
+				split, to = k, d
+				break
+			}
+		}
+		if split < 0 {
+			Reply(bot, chatID, usage)
+			return
+		}
+		found, _, err := findOwnEntries(ctx, store, chatID, ownerID, day, strings.Join(args[1:split], " "))
+		if err != nil {
+			Reply(bot, chatID, "Ошибка чтения расписания")
+			return
+		}
+		if len(found) != 1 {
+			if len(found) == 0 {
+				Reply(bot, chatID, "В "+dayName(day)+" нет такой записи в твоём расписании")
+			} else {
+				Reply(bot, chatID, "В этот день несколько таких записей, укажи время начала вместо названия")
+			}
+			return
+		}
+		e := found[0]
+		start, end := e.StartTime, e.EndTime
+		if len(args) > split+1 {
+			st, et, err := timeparse.ParseTimeRange(args[split+1])
+			if err != nil {
+				Reply(bot, chatID, "Не понял время: "+args[split+1])
+				return
+			}
+			if et == nil && e.EndTime != nil {
+				t := st.Add(entryDuration(e))
+				et = &t
+			}
+			start, end = st, et
+		}
+		cancelX := storage.ScheduleException{ChatID: chatID, UserID: ownerID, OwnerName: ownerName, Date: day,
+			Kind: storage.ExceptionCancel, StartTime: &e.StartTime, Title: e.Title}
+		addX := storage.ScheduleException{ChatID: chatID, UserID: ownerID, OwnerName: ownerName, Date: to,
+			Kind: storage.ExceptionAdd, StartTime: &start, EndTime: end, Title: e.Title}
+		if err := store.Exceptions().Add(ctx, cancelX, addX); err != nil {
+			logging.FromContext(ctx).Error("add schedule exception failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось сохранить")
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("Перенесено: %s %s → %s %s", e.Title, dayName(day), dayName(to), start.Format("15:04")))
+
+	case "exceptions", "исключения":
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		exs, err := store.Exceptions().Between(ctx, chatID, today, today.AddDate(1, 0, 0))
+		if err != nil {
+			Reply(bot, chatID, "Ошибка чтения расписания")
+			return
+		}
+		if len(exs) == 0 {
+			Reply(bot, chatID, "Разовых изменений нет")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("Разовые изменения (вернуть: /timetable restore #id):\n")
+		for _, x := range exs {
+			mark := "➕"
+			line := describeEntry(x.Entry())
+			if x.Kind == storage.ExceptionCancel {
+				mark, line = "✖️", x.Title
+				if x.StartTime != nil {
+					line = x.StartTime.Format("15:04") + " — " + x.Title
+				}
+				if x.OwnerName != "" {
+					line += " (" + x.OwnerName + ")"
+				}
+			}
+			fmt.Fprintf(&b, "#%d %s %s %s\n", x.ID, dayName(x.Date), mark, line)
+		}
+		Reply(bot, chatID, b.String())
+
+	case "restore", "вернуть":
+		if len(args) == 0 {
+			Reply(bot, chatID, "Пример: /timetable restore #12 (номера — в /timetable exceptions)")
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+		if err != nil {
+			Reply(bot, chatID, "Пример: /timetable restore #12 (номера — в /timetable exceptions)")
+			return
+		}
+		ok, err := store.Exceptions().Delete(ctx, chatID, ownerID, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("delete schedule exception failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось удалить")
+			return
+		}
+		if !ok {
+			Reply(bot, chatID, "Нет такого изменения в твоём расписании")
+			return
+		}
+		Reply(bot, chatID, "Разовое изменение удалено")
+	}
+}
+
+// handleHolidays показывает или меняет учёт производственного календаря.
+func handleHolidays(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, cs storage.ChatSettings, args []string) {
+	usage := "/timetable holidays on — в праздники расписание не показывается\n" +
+		"/timetable holidays all — ещё и пропускать повторяющиеся напоминания\n" +
+		"/timetable holidays off — не учитывать праздники"
+	if len(args) == 0 {
+		state := "не учитывается"
+		switch cs.Holidays {
+		case storage.HolidaysTimetable:
+			state = "учитывается для расписания"
+		case storage.HolidaysAll:
+			state = "учитывается для расписания и повторяющихся напоминаний"
+		}
+		Reply(bot, chatID, "Производственный календарь "+state+".\n"+usage)
+		return
+	}
+	var mode, reply string
+	switch strings.ToLower(args[0]) {
+	case "on", "вкл", "timetable":
+		mode, reply = storage.HolidaysTimetable, "В праздники расписание не показывается"
+	case "all", "все":
+		mode, reply = storage.HolidaysAll, "В праздники расписание не показывается, повторяющиеся напоминания пропускаются"
+	case "off", "выкл":
+		mode, reply = storage.HolidaysOff, "Праздники не учитываются"
+	default:
+		Reply(bot, chatID, usage)
+		return
+	}
+	if err := store.ChatSettings().UpsertHolidays(ctx, chatID, mode); err != nil {
+		logging.FromContext(ctx).Error("save holidays mode failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось сохранить")
+		return
+	}
+	if mode != storage.HolidaysOff {
+		loc := storage.LoadUserLocation(cs.TimeZone)
+		now := time.Now().In(loc)
+		for d := now; d.Before(now.AddDate(1, 0, 0)); d = d.AddDate(0, 0, 1) {
+			if holidays.DayOff(d) {
+				reply += "\nБлижайший праздничный день: " + d.Format("02.01.2006")
+				break
+			}
+		}
+	}
+	Reply(bot, chatID, reply)
+}
@@ -0,0 +1,266 @@
+package telegram
+
+import (
+	"TelegramBot/internal/backup"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxBackupFileSize — выгрузка с историей job'ов больше обычного импорта.
+const maxBackupFileSize = 10 << 20
+
+// backupImport — проверенная выгрузка, ожидающая подтверждения. userID —
+// кто прислал файл: в группе подтвердить может только он.
+type backupImport struct {
+	userID int64
+	dump   storage.ChatDump
+}
+
+var pendingBackups = newPendingSet[*backupImport](importTTL)
+
+func isBackupFile(doc *tgbotapi.Document) bool {
+	return strings.HasSuffix(strings.ToLower(doc.FileName), ".json") || doc.MimeType == "application/json"
+}
+
+// HandleExport присылает все данные чата файлом: JSON (по умолчанию),
+// который принимает /import, или zip с CSV-таблицами. В группе выгрузка
+// доступна только администратору: в ней ID участников.
+func HandleExport(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message, arg string) {
+	chatID := m.Chat.ID
+	if isGroup(m.Chat) && (m.From == nil || !isChatAdmin(ctx, bot, chatID, m.From.ID)) {
+		Reply(bot, chatID, "Выгрузить данные группы может только администратор")
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(arg))
+	if format != "" && format != "json" && format != "csv" {
+		Reply(bot, chatID, "Пример:\n/export — JSON, который можно загрузить через /import\n/export csv — таблицы для Excel")
+		return
+	}
+	dump, err := store.Transfer().Dump(ctx, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("export dump failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось выгрузить данные")
+		return
+	}
+	now := time.Now()
+	f := backup.FromDump(chatID, dump, now)
+	name := fmt.Sprintf("chat-%d-%s", chatID, now.UTC().Format("20060102"))
+
+	var data []byte
+	if format == "csv" {
+		data, err = backup.CSV(f)
+		name += "-csv.zip"
+	} else {
+		data, err = json.MarshalIndent(f, "", "  ")
+		name += ".json"
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("export encode failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось выгрузить данные")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = fmt.Sprintf("Напоминаний: %d, отправок: %d, записей расписания: %d, разовых изменений: %d",
+		len(dump.Reminders), len(dump.Jobs), len(dump.Schedule), len(dump.Exceptions))
+	if format != "csv" {
+		doc.Caption += "\nЧтобы восстановить, пришли этот файл в нужный чат с подписью /import"
+	}
+	if _, err := bot.Send(doc); err != nil {
+		logging.FromContext(ctx).Error("export send failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось отправить файл")
+	}
+}
+
+// exceptionKey — ключ разового изменения для поиска дубликатов.
+func exceptionKey(x storage.ScheduleException) string {
+	start := ""
+	if x.StartTime != nil {
+		start = x.StartTime.Format("15:04")
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s", x.Date.Format(time.DateOnly), x.Kind, start,
+		strings.ToLower(x.Title), ownerKey(x.UserID))
+}
+
+func ownerKey(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprint(*id)
+}
+
+// previewBackupImport проверяет выгрузку, отбрасывает то, что в чате уже
+// есть, и показывает, что будет восстановлено. В группе импорт доступен
+// только администратору.
+func previewBackupImport(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message, data []byte) {
+	chatID := m.Chat.ID
+	if isGroup(m.Chat) && (m.From == nil || !isChatAdmin(ctx, bot, chatID, m.From.ID)) {
+		Reply(bot, chatID, "Восстановить данные группы может только администратор")
+		return
+	}
+	f, err := backup.Decode(data)
+	if err != nil {
+		Reply(bot, chatID, "Не могу импортировать файл: "+err.Error())
+		return
+	}
+	in, err := f.Dump()
+	if err != nil {
+		Reply(bot, chatID, "В выгрузке ошибка, ничего не изменено: "+err.Error())
+		return
+	}
+	current, err := store.Transfer().Dump(ctx, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("import dump current failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось прочитать текущие данные чата")
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, r := range current.Reminders {
+		seen[reminderKey(r)] = true
+	}
+	for _, e := range current.Schedule {
+		seen[entryKey(e)+"|"+ownerKey(e.UserID)] = true
+	}
+	for _, x := range current.Exceptions {
+		seen[exceptionKey(x)] = true
+	}
+
+	// Владельцы записей из чужой выгрузки не переносятся (Restore их
+	// очищает), поэтому и дубликаты ищутся по тому, что будет записано.
+	foreign := in.ChatID != chatID
+	plan := storage.ChatDump{ChatID: in.ChatID, Settings: in.Settings}
+	dups := 0
+	for _, r := range in.Reminders {
+		if seen[reminderKey(r)] {
+			dups++
+			continue
+		}
+		seen[reminderKey(r)] = true
+		plan.Reminders = append(plan.Reminders, r)
+	}
+	kept := map[int64]bool{}
+	for _, r := range plan.Reminders {
+		kept[r.ID] = true
+	}
+	pending := 0
+	for _, j := range in.Jobs {
+		if !kept[j.ReminderID] {
+			continue
+		}
+		plan.Jobs = append(plan.Jobs, j)
+		if j.SentAt == nil && j.SkippedAt == nil && j.FailedAt == nil {
+			pending++
+		}
+	}
+	for _, e := range in.Schedule {
+		if foreign {
+			e.UserID, e.OwnerName = nil, ""
+		}
+		k := entryKey(e) + "|" + ownerKey(e.UserID)
+		if seen[k] {
+			dups++
+			continue
+		}
+		seen[k] = true
+		plan.Schedule = append(plan.Schedule, e)
+	}
+	for _, x := range in.Exceptions {
+		if foreign {
+			x.UserID, x.OwnerName = nil, ""
+		}
+		if seen[exceptionKey(x)] {
+			dups++
+			continue
+		}
+		seen[exceptionKey(x)] = true
+		plan.Exceptions = append(plan.Exceptions, x)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Выгрузка от %s (чат %d).\n", f.ExportedAt.Format("02.01.2006 15:04 MST"), f.ChatID)
+	if plan.Settings != nil {
+		fmt.Fprintf(&b, "Настройки чата будут заменены (часовой пояс %s).\n", plan.Settings.TimeZone)
+	}
+	fmt.Fprintf(&b, "Будет добавлено: напоминаний %d (ожидающих отправок %d), записей расписания %d, разовых изменений %d.",
+		len(plan.Reminders), pending, len(plan.Schedule), len(plan.Exceptions))
+	if dups > 0 {
+		fmt.Fprintf(&b, "\nУже есть в чате, пропущено: %d", dups)
+	}
+	if foreign && len(plan.Reminders)+len(plan.Schedule)+len(plan.Exceptions) > 0 {
+		b.WriteString("\nВыгрузка из другого чата: авторы и исполнители напоминаний и владельцы записей расписания не переносятся.")
+	}
+	if pending > 0 {
+		b.WriteString("\nОтправки, время которых прошло, уйдут по правилам догоняющей рассылки.")
+	}
+	if plan.Settings == nil && len(plan.Reminders) == 0 && len(plan.Schedule) == 0 && len(plan.Exceptions) == 0 {
+		Reply(bot, chatID, b.String()+"\nИмпортировать нечего.")
+		return
+	}
+
+	imp := &backupImport{dump: plan}
+	if m.From != nil {
+		imp.userID = m.From.ID
+	}
+	nonce := pendingBackups.put(chatID, imp)
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Восстановить", fmt.Sprintf("bk:ok:%s", nonce)),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", fmt.Sprintf("bk:cancel:%s", nonce)),
+		),
+	)
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("import preview send failed", logging.Err(err))
+	}
+}
+
+// handleBackupCallback: "ok:<nonce>" или "cancel:<nonce>".
+func handleBackupCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	action, nonce, _ := strings.Cut(arg, ":")
+
+	imp, ok := pendingBackups.get(chatID, nonce)
+	if ok && isGroup(cq.Message.Chat) && (cq.From == nil || cq.From.ID != imp.userID) {
+		answerCallback(ctx, bot, cq.ID, "Подтвердить может только тот, кто прислал файл")
+		return
+	}
+	// права могли отобрать, пока висело превью
+	if ok && action == "ok" && isGroup(cq.Message.Chat) && !isChatAdmin(ctx, bot, chatID, cq.From.ID) {
+		answerCallback(ctx, bot, cq.ID, "Восстановить данные группы может только администратор")
+		return
+	}
+	if !ok || !pendingBackups.remove(nonce) {
+		answerCallback(ctx, bot, cq.ID, "Импорт устарел, пришли файл ещё раз")
+		return
+	}
+
+	var result string
+	if action != "ok" {
+		answerCallback(ctx, bot, cq.ID, "Отменено")
+		result = "Импорт отменён"
+	} else {
+		st, err := store.Transfer().Restore(ctx, chatID, imp.dump)
+		if err != nil {
+			logging.FromContext(ctx).Error("import restore failed", logging.Err(err))
+			answerCallback(ctx, bot, cq.ID, "Не удалось импортировать")
+			return
+		}
+		answerCallback(ctx, bot, cq.ID, "Готово")
+		result = fmt.Sprintf("Восстановлено: напоминаний %d, отправок %d, записей расписания %d, разовых изменений %d",
+			st.Reminders, st.Jobs, st.Schedule, st.Exceptions)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
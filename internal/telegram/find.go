@@ -0,0 +1,155 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	findPageSize = 10
+	findTTL      = 30 * time.Minute
+)
+
+// findQueries — тексты поисков чата. Запрос не помещается в callback data,
+// поэтому кнопки листания ссылаются на него по nonce.
+var findQueries = newPendingSet[string](findTTL)
+
+// HandleFind ищет по напоминаниям, их истории и расписанию чата и
+// показывает первую страницу результатов.
+func HandleFind(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg string) {
+	query := strings.TrimSpace(arg)
+	if query == "" {
+		Reply(bot, chatID, "Пример: /find стоматолог")
+		return
+	}
+	nonce := findQueries.put(chatID, query)
+
+	text, markup, err := renderFindPage(ctx, store, chatID, query, 0, nonce)
+	if err != nil {
+		logging.FromContext(ctx).Error("find failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось выполнить поиск")
+		return
+	}
+	if markup == nil {
+		Reply(bot, chatID, text)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = *markup
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("find send failed", logging.Err(err))
+	}
+}
+
+// renderFindPage собирает страницу результатов с позиции offset. Клавиатура
+// листания возвращается, только если результатов больше одной страницы.
+func renderFindPage(ctx context.Context, store *storage.Storage, chatID int64, query string, offset int, nonce string) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hits, total, err := store.Search().Search(ctx, chatID, query, offset, findPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return fmt.Sprintf("По запросу «%s» ничего не найдено", query), nil, nil
+	}
+
+	cs, _ := store.ChatSettings().Get(ctx, chatID)
+	loc := storage.LoadUserLocation(cs.TimeZone)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "«%s» — найдено %d", query, total)
+	if total > findPageSize {
+		fmt.Fprintf(&b, ", %d–%d", offset+1, offset+len(hits))
+	}
+	b.WriteString(":\n")
+	for _, h := range hits {
+		switch h.Kind {
+		case storage.SearchSchedule:
+			fmt.Fprintf(&b, "📅 %s %s\n", weekdayShort[h.Entry.Weekday-1], describeEntry(*h.Entry))
+		case storage.SearchHistory:
+			r := h.Reminder
+			when := "—"
+			if r.FiredAt != nil {
+				when = r.FiredAt.In(loc).Format("02.01.2006 15:04")
+			}
+			fmt.Fprintf(&b, "🗂 %s — %s", when, r.Message)
+			if r.AcknowledgedAt != nil {
+				b.WriteString(" ✅")
+			}
+			b.WriteString("\n")
+		default:
+			r := h.Reminder
+			when := "—"
+			if r.EventTime != nil {
+				when = r.EventTime.In(loc).Format("Mon, 02 Jan 15:04")
+			} else if r.NextReport != nil {
+				when = r.NextReport.In(loc).Format("Mon, 02 Jan 15:04")
+			}
+			fmt.Fprintf(&b, "🔔 %s — %s", when, r.Message)
+			if chatID < 0 && r.AuthorName != "" {
+				fmt.Fprintf(&b, " (%s)", r.AuthorName)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if total <= findPageSize {
+		return b.String(), nil, nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️ Назад",
+			fmt.Sprintf("fd:%s:%d", nonce, max(offset-findPageSize, 0))))
+	}
+	if offset+findPageSize < total {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Дальше ▶️",
+			fmt.Sprintf("fd:%s:%d", nonce, offset+findPageSize)))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return b.String(), &markup, nil
+}
+
+// handleFindCallback: "<nonce>:<offset>" — перелистывает результаты в том
+// же сообщении.
+func handleFindCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	nonce, off, _ := strings.Cut(arg, ":")
+	offset, err := strconv.Atoi(off)
+	if err != nil || offset < 0 {
+		answerCallback(ctx, bot, cq.ID, "")
+		return
+	}
+
+	query, ok := findQueries.get(chatID, nonce)
+	if !ok {
+		answerCallback(ctx, bot, cq.ID, "Поиск устарел, повтори /find")
+		return
+	}
+
+	text, markup, err := renderFindPage(ctx, store, chatID, query, offset, nonce)
+	if err != nil {
+		logging.FromContext(ctx).Error("find page failed", logging.Err(err))
+		answerCallback(ctx, bot, cq.ID, "Не удалось выполнить поиск")
+		return
+	}
+	answerCallback(ctx, bot, cq.ID, "")
+
+	var edit tgbotapi.EditMessageTextConfig
+	if markup != nil {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, cq.Message.MessageID, text, *markup)
+	} else {
+		edit = tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, text)
+	}
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const forgetTTL = 5 * time.Minute
+
+// pendingForget — запрос /forget, ожидающий подтверждения. userID — кто
+// запросил: в группе подтвердить может только он.
+type pendingForget struct {
+	userID int64
+}
+
+var pendingForgets = newPendingSet[pendingForget](forgetTTL)
+
+func isChatAdmin(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) bool {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("get chat member failed", logging.Err(err))
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// HandleForget спрашивает подтверждение на удаление всех данных чата. В
+// группе удалить данные может только администратор.
+func HandleForget(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message) {
+	chatID := m.Chat.ID
+	if m.From == nil {
+		return
+	}
+	if isGroup(m.Chat) && !isChatAdmin(ctx, bot, chatID, m.From.ID) {
+		Reply(bot, chatID, "Удалить данные группы может только администратор")
+		return
+	}
+	dump, err := store.Transfer().Dump(ctx, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("forget dump failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось прочитать данные чата")
+		return
+	}
+
+	nonce := pendingForgets.put(chatID, pendingForget{userID: m.From.ID})
+
+	text := fmt.Sprintf("⚠️ Будут безвозвратно удалены все данные этого чата: настройки, напоминаний %d "+
+		"вместе с историей отправок, записей расписания %d, разовых изменений %d, токены API и ссылка на календарь.\n"+
+		"Запланированные напоминания больше не придут. Сохранить копию можно через /export.",
+		len(dump.Reminders), len(dump.Schedule), len(dump.Exceptions))
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить всё", fmt.Sprintf("fg:ok:%s", nonce)),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", fmt.Sprintf("fg:cancel:%s", nonce)),
+		),
+	)
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("forget prompt send failed", logging.Err(err))
+	}
+}
+
+// dropPending забывает все ожидающие подтверждения чата.
+func dropPending(chatID int64) {
+	pendingImports.drop(chatID)
+	pendingReminders.drop(chatID)
+	pendingTimetables.drop(chatID)
+	pendingBackups.drop(chatID)
+	findQueries.drop(chatID)
+}
+
+// handleForgetCallback: "ok:<nonce>" или "cancel:<nonce>".
+func handleForgetCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	action, nonce, _ := strings.Cut(arg, ":")
+
+	p, ok := pendingForgets.get(chatID, nonce)
+	if ok && (cq.From == nil || cq.From.ID != p.userID) {
+		answerCallback(ctx, bot, cq.ID, "Подтвердить может только тот, кто вызвал /forget")
+		return
+	}
+	if !ok || !pendingForgets.remove(nonce) {
+		answerCallback(ctx, bot, cq.ID, "Запрос устарел, вызови /forget ещё раз")
+		return
+	}
+
+	var result string
+	if action != "ok" {
+		answerCallback(ctx, bot, cq.ID, "Отменено")
+		result = "Удаление отменено"
+	} else {
+		kind := "private"
+		if isGroup(cq.Message.Chat) {
+			kind = "group"
+		}
+		deleted, err := store.Forget().ForgetChat(ctx, chatID, kind)
+		if err != nil {
+			logging.FromContext(ctx).Error("forget chat failed", logging.Err(err))
+			answerCallback(ctx, bot, cq.ID, "Не удалось удалить")
+			return
+		}
+		dropPending(chatID)
+		var total int64
+		for _, n := range deleted {
+			total += n
+		}
+		// журнал без chat_id и имён: только тип чата и объём
+		slog.Info("chat data forgotten", "chat_kind", kind, "rows", total)
+		answerCallback(ctx, bot, cq.ID, "Удалено")
+		result = fmt.Sprintf("Все данные чата удалены (строк: %d).", total)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,228 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var reBroadcast = regexp.MustCompile(`(?i)^(напомни\s+)?всем[\s,:!]+`)
+
+func isGroup(c *tgbotapi.Chat) bool {
+	return c != nil && (c.IsGroup() || c.IsSuperGroup())
+}
+
+// addressedText возвращает текст сообщения, обращённого к боту: без
+// «@имя_бота» в команде и в упоминании. В группах бот отвечает только на
+// команды, упоминания и ответы на свои сообщения — для остального ok=false.
+func addressedText(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (string, bool) {
+	raw, entities := m.Text, m.Entities
+	if m.Document != nil {
+		raw, entities = m.Caption, m.CaptionEntities
+	}
+	text := strings.TrimSpace(raw)
+
+	if strings.HasPrefix(text, "/") {
+		cmd, rest, _ := strings.Cut(text, " ")
+		if name, target, ok := strings.Cut(cmd, "@"); ok {
+			// команда адресована другому боту
+			if !strings.EqualFold(target, bot.Self.UserName) {
+				return "", false
+			}
+			text = strings.TrimSpace(name + " " + rest)
+		}
+		return text, true
+	}
+	if !isGroup(m.Chat) {
+		return text, true
+	}
+
+	// Упоминание ищется по сущностям: смещения в них точные (в UTF-16), а
+	// поиск по тексту в нижнем регистре сбивается, если lower-case меняет
+	// длину символов в байтах.
+	for _, e := range entities {
+		if e.Type != "mention" {
+			continue
+		}
+		if !strings.EqualFold(utf16Slice(raw, e.Offset, e.Offset+e.Length), "@"+bot.Self.UserName) {
+			continue
+		}
+		text = utf16Slice(raw, 0, e.Offset) + utf16Slice(raw, e.Offset+e.Length, -1)
+		return strings.Trim(strings.TrimSpace(text), " ,:"), true
+	}
+	if r := m.ReplyToMessage; r != nil && r.From != nil && r.From.ID == bot.Self.ID {
+		return text, true
+	}
+	return "", false
+}
+
+// cutBroadcast снимает префикс «напомни всем» в группе.
+func cutBroadcast(m *tgbotapi.Message, text string) (string, bool) {
+	if !isGroup(m.Chat) {
+		return text, false
+	}
+	if loc := reBroadcast.FindStringIndex(text); loc != nil {
+		return text[loc[1]:], true
+	}
+	return text, false
+}
+
+func authorName(u *tgbotapi.User) string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.UserName
+	}
+	return name
+}
+
+// addressee в группе упоминает исполнителя (или автора) напоминания либо
+// помечает рассылку для всех. Упоминание — сущность text_mention, так что
+// работает и без username, а текст не нужно экранировать.
+func addressee(msg *tgbotapi.MessageConfig, j storage.Job) {
+	switch {
+	case j.Broadcast:
+		msg.Text = "📢 Всем! " + msg.Text
+	case j.AssigneeID != nil && j.ChatID < 0:
+		mention(msg, *j.AssigneeID, j.AssigneeName)
+	case j.UserID != nil && j.ChatID < 0:
+		mention(msg, *j.UserID, j.AuthorName)
+	}
+}
+
+func mention(msg *tgbotapi.MessageConfig, userID int64, name string) {
+	if name == "" {
+		name = "Участник"
+	}
+	msg.Text = name + ", " + msg.Text
+	msg.Entities = append(msg.Entities, tgbotapi.MessageEntity{
+		Type:   "text_mention",
+		Offset: 0,
+		Length: len(utf16.Encode([]rune(name))),
+		User:   &tgbotapi.User{ID: userID},
+	})
+}
+
+type seenUser struct {
+	key     string
+	private bool
+}
+
+// seenUsers — кого уже записали в known_users, чтобы не писать в БД на
+// каждое сообщение.
+var seenUsers sync.Map
+
+// trackUser запоминает отправителя, чтобы находить его потом по @username.
+func trackUser(ctx context.Context, store *storage.Storage, m *tgbotapi.Message) {
+	u := m.From
+	if u == nil || u.IsBot {
+		return
+	}
+	private := m.Chat != nil && m.Chat.IsPrivate()
+	key := u.UserName + "|" + u.FirstName + "|" + u.LastName
+	if v, ok := seenUsers.Load(u.ID); ok {
+		if s := v.(seenUser); s.key == key && (s.private || !private) {
+			return
+		}
+	}
+	ku := storage.KnownUser{ID: u.ID, Username: u.UserName, FirstName: u.FirstName, LastName: u.LastName}
+	if err := store.Users().Touch(ctx, ku, private); err != nil {
+		logging.FromContext(ctx).Warn("track user failed", logging.Err(err))
+		return
+	}
+	if v, ok := seenUsers.Load(u.ID); ok {
+		private = private || v.(seenUser).private
+	}
+	seenUsers.Store(u.ID, seenUser{key: key, private: private})
+}
+
+// errNotMember — упомянутый исполнитель не состоит в группе.
+var errNotMember = errors.New("assignee is not a member of the chat")
+
+// isChatMember — пользователь сейчас состоит в чате.
+func isChatMember(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) bool {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("get chat member failed", logging.Err(err))
+		return false
+	}
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	}
+	return false
+}
+
+// leadingAssignee ищет исполнителя в начале текста: text_mention (у
+// пользователя нет username) или @username из known_users. mentioned=true
+// и u=nil — упомянут пользователь, которого бот ещё не видел. known_users
+// общая для всех чатов, поэтому найденный пользователь проверяется на
+// членство в группе: иначе errNotMember.
+func leadingAssignee(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message, text string) (u *storage.KnownUser, rest string, mentioned bool, err error) {
+	for _, e := range m.Entities {
+		if e.Type != "text_mention" || e.User == nil {
+			continue
+		}
+		name := utf16Slice(m.Text, e.Offset, e.Offset+e.Length)
+		if name == "" || !strings.HasPrefix(text, name) {
+			continue
+		}
+		ku := storage.KnownUser{ID: e.User.ID, Username: e.User.UserName, FirstName: e.User.FirstName, LastName: e.User.LastName}
+		if !isChatMember(ctx, bot, m.Chat.ID, ku.ID) {
+			return nil, text, true, errNotMember
+		}
+		return &ku, strings.Trim(text[len(name):], " ,:"), true, nil
+	}
+
+	if !strings.HasPrefix(text, "@") {
+		return nil, text, false, nil
+	}
+	tok, rest, _ := strings.Cut(text, " ")
+	username := strings.TrimRight(strings.TrimPrefix(tok, "@"), ",:")
+	if username == "" || strings.EqualFold(username, bot.Self.UserName) {
+		return nil, text, false, nil
+	}
+	ku, found, err := store.Users().ByUsername(ctx, username)
+	if err != nil || !found {
+		return nil, rest, true, err
+	}
+	if !isChatMember(ctx, bot, m.Chat.ID, ku.ID) {
+		return nil, rest, true, errNotMember
+	}
+	return &ku, strings.TrimSpace(rest), true, nil
+}
+
+// utf16Slice вырезает подстроку по смещениям в UTF-16, как их считает
+// Telegram в MessageEntity. to < 0 — до конца строки.
+func utf16Slice(s string, from, to int) string {
+	u := utf16.Encode([]rune(s))
+	if to < 0 || to > len(u) {
+		to = len(u)
+	}
+	if from < 0 || from > to {
+		return ""
+	}
+	return string(utf16.Decode(u[from:to]))
+}
+
+// toAssignee переадресует напоминание исполнителю в личку.
+func toAssignee(msg tgbotapi.MessageConfig, j storage.Job) tgbotapi.MessageConfig {
+	dm := msg
+	dm.ChatID = *j.AssigneeID
+	dm.Entities = nil
+	dm.Text = reminderText(j)
+	if j.AuthorName != "" {
+		dm.Text += "\n👥 Из группы, назначил(а) " + j.AuthorName
+	}
+	return dm
+}
@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const historyLimit = 50
+
+// HandleHistory показывает отработавшие разовые напоминания за сегодня,
+// неделю или месяц: когда пришли, отмечены ли выполненными и сколько раз
+// их откладывали.
+func HandleHistory(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cs, _ := store.ChatSettings().Get(ctx, chatID)
+	loc := storage.LoadUserLocation(cs.TimeZone)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var from time.Time
+	var title string
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "", "today", "сегодня":
+		from, title = today, "сегодня"
+	case "week", "неделя":
+		from, title = today.AddDate(0, 0, -6), "неделю"
+	case "month", "месяц":
+		from, title = today.AddDate(0, -1, 0), "месяц"
+	default:
+		Reply(bot, chatID, "Пример: /history today | week | month")
+		return
+	}
+
+	items, err := store.Reminders().History(ctx, chatID, from.UTC(), now.AddDate(0, 0, 1).UTC(), historyLimit)
+	if err != nil {
+		logging.FromContext(ctx).Error("history failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось получить историю")
+		return
+	}
+	if len(items) == 0 {
+		Reply(bot, chatID, "За "+title+" отработавших напоминаний нет")
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "История за %s:\n", title)
+	for _, r := range items {
+		when := "—"
+		switch {
+		case r.FiredAt != nil:
+			when = r.FiredAt.In(loc).Format("Mon, 02 Jan 15:04")
+		case r.EventTime != nil:
+			when = r.EventTime.In(loc).Format("Mon, 02 Jan 15:04")
+		}
+		fmt.Fprintf(&b, "• %s — %s", when, r.Message)
+		switch {
+		case r.AcknowledgedAt != nil:
+			fmt.Fprintf(&b, " ✅ %s", r.AcknowledgedAt.In(loc).Format("15:04"))
+		case r.FiredAt == nil:
+			b.WriteString(" ⏭ не отправлено")
+		}
+		if r.SnoozedCount > 0 {
+			fmt.Fprintf(&b, " (откладывалось: %d)", r.SnoozedCount)
+		}
+		if chatID < 0 && r.AuthorName != "" {
+			fmt.Fprintf(&b, " (%s)", r.AuthorName)
+		}
+		b.WriteString("\n")
+	}
+	if len(items) == historyLimit {
+		fmt.Fprintf(&b, "Показаны последние %d", historyLimit)
+	}
+	Reply(bot, chatID, b.String())
+}
@@ -0,0 +1,437 @@
+package telegram
+
+import (
+	"TelegramBot/internal/ical"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxImportFileSize = 1 << 20
+	importTTL         = 30 * time.Minute
+	importPreviewMax  = 20
+)
+
+// windowsZones — имена зон, которые Outlook пишет в TZID вместо IANA.
+var windowsZones = map[string]string{
+	"Russian Standard Time":         "Europe/Moscow",
+	"Kaliningrad Standard Time":     "Europe/Kaliningrad",
+	"Russia Time Zone 3":            "Europe/Samara",
+	"Ekaterinburg Standard Time":    "Asia/Yekaterinburg",
+	"Omsk Standard Time":            "Asia/Omsk",
+	"N. Central Asia Standard Time": "Asia/Novosibirsk",
+	"North Asia Standard Time":      "Asia/Krasnoyarsk",
+	"North Asia East Standard Time": "Asia/Irkutsk",
+	"Yakutsk Standard Time":         "Asia/Yakutsk",
+	"Vladivostok Standard Time":     "Asia/Vladivostok",
+	"Magadan Standard Time":         "Asia/Magadan",
+	"Russia Time Zone 11":           "Asia/Kamchatka",
+	"GMT Standard Time":             "Europe/London",
+	"W. Europe Standard Time":       "Europe/Berlin",
+	"Central Europe Standard Time":  "Europe/Budapest",
+	"E. Europe Standard Time":       "Europe/Chisinau",
+	"FLE Standard Time":             "Europe/Kiev",
+	"Eastern Standard Time":         "America/New_York",
+	"Central Standard Time":         "America/Chicago",
+	"Pacific Standard Time":         "America/Los_Angeles",
+	"UTC":                           "UTC",
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// icsImport — разобранный файл, ожидающий подтверждения.
+type icsImport struct {
+	reminders []storage.Reminder
+}
+
+var pendingImports = newPendingSet[*icsImport](importTTL)
+
+// HandleDocument принимает .ics-файл или таблицу расписания (CSV, TSV),
+// показывает, что будет импортировано, и ждёт подтверждения кнопкой.
+func HandleDocument(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, m *tgbotapi.Message) {
+	chatID := m.Chat.ID
+	doc := m.Document
+	table, dump := isTableFile(doc), isBackupFile(doc)
+	if !table && !dump && !strings.HasSuffix(strings.ToLower(doc.FileName), ".ics") && doc.MimeType != "text/calendar" {
+		Reply(bot, chatID, "Я умею импортировать календари в формате .ics, расписание в CSV или TSV и выгрузку /export (.json)")
+		return
+	}
+	limit := maxImportFileSize
+	if dump {
+		limit = maxBackupFileSize
+	}
+	if doc.FileSize > limit {
+		Reply(bot, chatID, fmt.Sprintf("Файл слишком большой, максимум %d МБ", limit>>20))
+		return
+	}
+
+	data, err := downloadFile(bot, doc.FileID, int64(limit))
+	if err != nil {
+		logging.FromContext(ctx).Error("document download failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось скачать файл, попробуй ещё раз")
+		return
+	}
+	if dump {
+		previewBackupImport(ctx, bot, store, m, data)
+		return
+	}
+	if table {
+		var owner *tgbotapi.User
+		if isGroup(m.Chat) {
+			owner = m.From
+		}
+		previewTimetableImport(ctx, bot, store, chatID, owner, data)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	cs, _ := store.ChatSettings().Get(ctx, chatID)
+	tz := cs.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	chatLoc := storage.LoadUserLocation(tz)
+
+	// неизвестную зону не подменяем молча на UTC: берём зону чата и
+	// сообщаем об этом в превью
+	unknownZones := map[string]bool{}
+	events, err := ical.Parse(data, func(tzid string) *time.Location {
+		if tzid == "" {
+			return chatLoc
+		}
+		name := tzid
+		if iana, ok := windowsZones[name]; ok {
+			name = iana
+		}
+		name = strings.TrimPrefix(name, "/")
+		if loc, ok := storage.LoadFixedUTC(name); ok {
+			return loc
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+		unknownZones[tzid] = true
+		return chatLoc
+	})
+	if err != nil {
+		Reply(bot, chatID, "Это не похоже на файл календаря (.ics)")
+		return
+	}
+
+	existing, err := store.Reminders().ListAll(ctx, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("ics list reminders failed", logging.Err(err))
+		Reply(bot, chatID, "Не удалось прочитать текущие напоминания")
+		return
+	}
+	seen := map[string]bool{}
+	for _, r := range existing {
+		seen[reminderKey(r)] = true
+	}
+
+	var (
+		plan                             []storage.Reminder
+		past, dups, unsupported, bounded int
+		now                              = time.Now()
+	)
+	for _, e := range events {
+		if boundedSeries(e, chatLoc, now) {
+			bounded++
+			continue
+		}
+		rems, ok := remindersFromEvent(e, chatID, tz, chatLoc, now)
+		if !ok {
+			unsupported++
+			continue
+		}
+		if len(rems) == 0 {
+			past++
+			continue
+		}
+		for _, r := range rems {
+			if seen[reminderKey(r)] {
+				dups++
+				continue
+			}
+			seen[reminderKey(r)] = true
+			plan = append(plan, r)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "В файле событий: %d.\n", len(events))
+	if len(plan) == 0 {
+		b.WriteString("Импортировать нечего.")
+	} else {
+		fmt.Fprintf(&b, "Будет добавлено напоминаний: %d\n", len(plan))
+		for i, r := range plan {
+			if i == importPreviewMax {
+				fmt.Fprintf(&b, "… и ещё %d\n", len(plan)-i)
+				break
+			}
+			b.WriteString("• " + describeImported(r, chatLoc) + "\n")
+		}
+	}
+	if past > 0 {
+		fmt.Fprintf(&b, "\nПропущено прошедших: %d", past)
+	}
+	if dups > 0 {
+		fmt.Fprintf(&b, "\nПропущено дубликатов: %d", dups)
+	}
+	if unsupported > 0 {
+		fmt.Fprintf(&b, "\nПропущено с неподдерживаемым повтором: %d", unsupported)
+	}
+	if bounded > 0 {
+		fmt.Fprintf(&b, "\nПропущено серий с окончанием (UNTIL, COUNT) или исключёнными датами: %d — такие повторы я не поддерживаю, добавь их вручную", bounded)
+	}
+	if len(unknownZones) > 0 {
+		zones := make([]string, 0, len(unknownZones))
+		for z := range unknownZones {
+			zones = append(zones, z)
+		}
+		sort.Strings(zones)
+		fmt.Fprintf(&b, "\n⚠️ Неизвестный часовой пояс %s — время событий в нём взято в поясе чата (%s), проверь его", strings.Join(zones, ", "), tz)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	if len(plan) > 0 {
+		nonce := pendingImports.put(chatID, &icsImport{reminders: plan})
+
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Импортировать", fmt.Sprintf("ics:ok:%s", nonce)),
+				tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", fmt.Sprintf("ics:cancel:%s", nonce)),
+			),
+		)
+	}
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("ics preview send failed", logging.Err(err))
+	}
+}
+
+// boundedSeries — повторяющееся событие с окончанием через COUNT или UNTIL
+// в будущем либо с исключёнными датами впереди. Напоминания не хранят ни
+// конца серии, ни исключений, поэтому такие серии не импортируются.
+func boundedSeries(e ical.Event, loc *time.Location, now time.Time) bool {
+	if e.RRule == "" {
+		return false
+	}
+	rp := ical.RuleParts(e.RRule)
+	if rp["COUNT"] != "" {
+		return true
+	}
+	if until := rp["UNTIL"]; until != "" {
+		if t, err := parseUntil(until, loc); err != nil || !t.Before(now) {
+			return true
+		}
+	}
+	for _, x := range e.ExDates {
+		if x.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// remindersFromEvent переводит событие в напоминания. ok=false — повтор,
+// который нельзя выразить еженедельными правилами; пустой срез — событие
+// уже прошло. Серия становится одним напоминанием с правилом на все её дни.
+func remindersFromEvent(e ical.Event, chatID int64, tz string, loc *time.Location, now time.Time) ([]storage.Reminder, bool) {
+	title := strings.TrimSpace(e.Summary)
+	if title == "" {
+		title = "дело"
+	}
+	start := e.Start
+	if e.AllDay {
+		// у события на весь день нет времени — напоминаем утром
+		d := e.Start.In(loc)
+		start = time.Date(d.Year(), d.Month(), d.Day(), 9, 0, 0, 0, loc)
+	}
+	base := storage.Reminder{
+		ChatID:          chatID,
+		Message:         title,
+		ReminderOffsets: timeparse.NormalizeOffsets(e.Alarms),
+	}
+
+	if e.RRule == "" {
+		if !start.After(now) || excluded(e.ExDates, start) {
+			return nil, true
+		}
+		at := start.UTC()
+		base.EventTime = &at
+		return []storage.Reminder{base}, true
+	}
+
+	rp := ical.RuleParts(e.RRule)
+	if rp["INTERVAL"] != "" && rp["INTERVAL"] != "1" {
+		return nil, false
+	}
+	if until := rp["UNTIL"]; until != "" {
+		if t, err := parseUntil(until, loc); err == nil && t.Before(now) {
+			return nil, true
+		}
+	}
+
+	local := start.In(loc)
+	var rule string
+	switch rp["FREQ"] {
+	case "DAILY":
+		if rp["BYDAY"] != "" {
+			return nil, false
+		}
+		rule = fmt.Sprintf("FREQ=DAILY;BYHOUR=%d;BYMINUTE=%d", local.Hour(), local.Minute())
+	case "WEEKLY":
+		var days []string
+		if rp["BYDAY"] == "" {
+			for code, wd := range weekdayCodes {
+				if wd == local.Weekday() {
+					days = []string{code}
+				}
+			}
+		}
+		for _, d := range strings.Split(rp["BYDAY"], ",") {
+			if d == "" {
+				continue
+			}
+			if _, ok := weekdayCodes[d]; !ok {
+				return nil, false
+			}
+			days = append(days, d)
+		}
+		sort.Slice(days, func(i, j int) bool {
+			return (weekdayCodes[days[i]]+6)%7 < (weekdayCodes[days[j]]+6)%7
+		})
+		rule = fmt.Sprintf("FREQ=WEEKLY;BYDAY=%s;BYHOUR=%d;BYMINUTE=%d", strings.Join(days, ","), local.Hour(), local.Minute())
+	default:
+		return nil, false
+	}
+
+	from := now
+	if start.After(now) {
+		from = start.Add(-time.Second)
+	}
+	next := storage.NextFromWeeklyRRULE(rule, tz, from)
+	base.ReminderRule = &rule
+	base.NextReport = &next
+	return []storage.Reminder{base}, true
+}
+
+func parseUntil(v string, loc *time.Location) (time.Time, error) {
+	if len(v) == 8 {
+		return time.ParseInLocation("20060102", v, loc)
+	}
+	if strings.HasSuffix(v, "Z") {
+		return time.Parse("20060102T150405Z", v)
+	}
+	return time.ParseInLocation("20060102T150405", v, loc)
+}
+
+func excluded(exdates []time.Time, t time.Time) bool {
+	for _, x := range exdates {
+		if x.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// reminderKey — ключ для поиска дубликатов: текст плюс время или правило.
+func reminderKey(r storage.Reminder) string {
+	key := strings.ToLower(strings.TrimSpace(r.Message))
+	switch {
+	case r.ReminderRule != nil:
+		return key + "|" + *r.ReminderRule
+	case r.EventTime != nil:
+		return key + "|" + r.EventTime.UTC().Format(time.RFC3339)
+	}
+	return key
+}
+
+func describeImported(r storage.Reminder, loc *time.Location) string {
+	if r.ReminderRule != nil && r.NextReport != nil {
+		every := "каждую неделю"
+		if strings.HasPrefix(*r.ReminderRule, "FREQ=DAILY") {
+			every = "каждый день"
+		}
+		return fmt.Sprintf("%s, ближайшее %s — %s", every,
+			r.NextReport.In(loc).Format("Mon, 02 Jan 15:04"), r.Message)
+	}
+	return fmt.Sprintf("%s — %s", r.EventTime.In(loc).Format("Mon, 02 Jan 15:04"), r.Message)
+}
+
+func downloadFile(bot *tgbotapi.BotAPI, fileID string, limit int64) ([]byte, error) {
+	url, err := bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// handleImportCallback подтверждает или отменяет импорт из HandleDocument.
+func handleImportCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	action, nonce, _ := strings.Cut(arg, ":")
+
+	imp, ok := pendingImports.get(chatID, nonce)
+	if !ok || !pendingImports.remove(nonce) {
+		answerCallback(ctx, bot, cq.ID, "Импорт устарел, пришли файл ещё раз")
+		return
+	}
+
+	var result string
+	if action != "ok" {
+		answerCallback(ctx, bot, cq.ID, "Отменено")
+		result = "Импорт отменён"
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		now := time.Now().UTC()
+		created := 0
+		for i := range imp.reminders {
+			r := &imp.reminders[i]
+			id, err := store.Reminders().Create(ctx, r)
+			if err != nil {
+				logging.FromContext(ctx).Error("ics create reminder failed", logging.Err(err))
+				continue
+			}
+			due := r.EventTime
+			if due == nil {
+				due = r.NextReport
+			}
+			if err := store.Jobs().CreateForEvent(ctx, id, *due, r.ReminderOffsets, now); err != nil {
+				logging.FromContext(ctx).Error("ics schedule jobs failed", "reminder_id", id, logging.Err(err))
+			}
+			created++
+		}
+		answerCallback(ctx, bot, cq.ID, "Готово")
+		result = fmt.Sprintf("Импортировано напоминаний: %d из %d", created, len(imp.reminders))
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"TelegramBot/internal/metrics"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UpdateKind — тип апдейта для меток метрик.
+func UpdateKind(u tgbotapi.Update) string {
+	switch {
+	case u.Message != nil:
+		return "message"
+	case u.EditedMessage != nil:
+		return "edited_message"
+	case u.CallbackQuery != nil:
+		return "callback_query"
+	case u.MyChatMember != nil:
+		return "my_chat_member"
+	default:
+		return "other"
+	}
+}
+
+var knownCommands = map[string]bool{
+	"start": true, "timezone": true, "report": true, "quiet": true, "list": true,
+	"timetable": true, "calendar": true, "token": true, "jobs": true,
+	"export": true, "import": true, "forget": true, "history": true, "find": true,
+}
+
+// commandLabel сводит текст сообщения к ограниченному набору меток, чтобы
+// произвольные команды не раздували число серий.
+func commandLabel(m *tgbotapi.Message) string {
+	if m.Document != nil {
+		return "document"
+	}
+	text := strings.TrimSpace(m.Text)
+	if !strings.HasPrefix(text, "/") {
+		return "text"
+	}
+	cmd := strings.TrimPrefix(strings.Fields(text)[0], "/")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	if knownCommands[cmd] {
+		return cmd
+	}
+	return "other"
+}
+
+// InstrumentClient оборачивает HTTP-клиент бота и считает ошибки Bot API
+// по методу и коду — так учитываются все вызовы, а не только рассылка.
+func InstrumentClient(bot *tgbotapi.BotAPI) {
+	bot.Client = &apiClient{next: bot.Client}
+}
+
+type apiClient struct {
+	next tgbotapi.HTTPClient
+}
+
+func (c *apiClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	resp, err := c.next.Do(req)
+	if err != nil {
+		metrics.TelegramErrors.WithLabelValues(method, "network").Inc()
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
+	body, rerr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	code := strconv.Itoa(resp.StatusCode)
+	var apiResp struct {
+		ErrorCode int `json:"error_code"`
+	}
+	if rerr == nil && json.Unmarshal(body, &apiResp) == nil && apiResp.ErrorCode != 0 {
+		code = strconv.Itoa(apiResp.ErrorCode)
+	}
+	metrics.TelegramErrors.WithLabelValues(method, code).Inc()
+	return resp, nil
+}
@@ -1,86 +1,577 @@
 package telegram
 
 import (
+	"TelegramBot/internal/holidays"
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/metrics"
 	"TelegramBot/internal/storage"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// CatchUpPolicy определяет, что делать с job'ами, опоздавшими больше чем на
+// Notifier.MaxLate (обычно после простоя бота).
+type CatchUpPolicy string
+
+const (
+	// CatchUpAll — отправить всё, пометив опоздавшие напоминания.
+	CatchUpAll CatchUpPolicy = "all"
+	// CatchUpRecent — опоздавшие больше MaxLate пропустить.
+	CatchUpRecent CatchUpPolicy = "recent"
+	// CatchUpDigest — собрать опоздавшие в одно сообщение на чат.
+	CatchUpDigest CatchUpPolicy = "digest"
+)
+
 type Notifier struct {
-	Bot   *tgbotapi.BotAPI
-	Store *storage.Storage
+	Bot         *tgbotapi.BotAPI
+	Store       *storage.Storage
+	MaxAttempts int
+	CatchUp     CatchUpPolicy
+	MaxLate     time.Duration
+	// Retention — сколько хранить историю напоминаний; 0 — не чистить.
+	Retention time.Duration
 
 	lastDigest map[int64]time.Time
-	mu         sync.Mutex
+	// delivered — захваченные job'ы, сообщение по которым точно ушло, а
+	// sent_at ещё не записан.
+	delivered map[int64]bool
+	mu        sync.Mutex
+	// lastTick — unix-время последнего прохода цикла Run в наносекундах.
+	lastTick atomic.Int64
 }
 
+const (
+	// sweepInterval — страховочный проход по reminder_jobs на случай пропущенного сигнала.
+	sweepInterval = 5 * time.Minute
+	// purgeInterval — как часто удалять историю старше Retention.
+	purgeInterval = time.Hour
+	// exceptionsKeep — сколько хранить прошедшие исключения расписания: неделю
+	// назад ещё показывают /timetable show и календарь.
+	exceptionsKeep = 7 * 24 * time.Hour
+	// minJobsSleep не даёт таймеру крутиться вхолостую, если задача уже просрочена.
+	minJobsSleep = 200 * time.Millisecond
+
+	defaultMaxAttempts = 5
+	retryBaseDelay     = 1 * time.Minute
+	retryMaxDelay      = 1 * time.Hour
+	defaultMaxLate     = 15 * time.Minute
+	// confirmDelay — через сколько после захвата повторить запись отправки,
+	// если MarkSent сразу после Send не прошёл.
+	confirmDelay = 30 * time.Second
+)
+
 func (n *Notifier) Run(ctx context.Context) {
 	if n.lastDigest == nil {
 		n.lastDigest = make(map[int64]time.Time)
 	}
-	jobsTicker := time.NewTicker(1 * time.Minute)
+	if n.delivered == nil {
+		n.delivered = make(map[int64]bool)
+	}
+	if n.MaxAttempts <= 0 {
+		n.MaxAttempts = defaultMaxAttempts
+	}
+	if n.CatchUp == "" {
+		n.CatchUp = CatchUpAll
+	}
+	if n.MaxLate <= 0 {
+		n.MaxLate = defaultMaxLate
+	}
+	ctx = logging.WithContext(ctx, slog.With("component", "notifier"))
+
+	jobsTimer := time.NewTimer(0)
+	sweepTicker := time.NewTicker(sweepInterval)
 	digestTicker := time.NewTicker(30 * time.Second)
-	defer jobsTicker.Stop()
+	purgeTicker := time.NewTicker(purgeInterval)
+	defer purgeTicker.Stop()
+	defer jobsTimer.Stop()
+	defer sweepTicker.Stop()
 	defer digestTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-jobsTicker.C:
-			n.processDueJobs()
+		case <-jobsTimer.C:
+			n.processDueJobs(ctx)
+			jobsTimer.Reset(n.untilNextJob(ctx))
+		case <-n.Store.JobsChanged():
+			jobsTimer.Reset(n.untilNextJob(ctx))
+		case <-sweepTicker.C:
+			n.processDueJobs(ctx)
+			jobsTimer.Reset(n.untilNextJob(ctx))
 		case <-digestTicker.C:
-			n.processDailyDigests()
+			n.processDailyDigests(ctx)
+		case <-purgeTicker.C:
+			n.purgeHistory(ctx)
 		}
+		n.lastTick.Store(time.Now().UnixNano())
+	}
+}
+
+// LastTick — когда цикл уведомлений последний раз проснулся. Нулевое время,
+// если Run ещё не запускался.
+func (n *Notifier) LastTick() time.Time {
+	ns := n.lastTick.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// untilNextJob возвращает, сколько спать до ближайшей неотправленной задачи.
+// Если задач нет или БД недоступна — спим до следующего страховочного прохода.
+func (n *Notifier) untilNextJob(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	next, err := n.Store.Jobs().NextDue(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("jobs.NextDue failed", logging.Err(err))
+		return sweepInterval
+	}
+	if next == nil {
+		return sweepInterval
+	}
+	d := time.Until(*next)
+	if d < minJobsSleep {
+		return minJobsSleep
+	}
+	if d > sweepInterval {
+		return sweepInterval
 	}
+	return d
 }
 
-func (n *Notifier) processDueJobs() {
-	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
+func (n *Notifier) processDueJobs(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
 	defer cancel()
 
 	now := time.Now().UTC()
+	n.confirmClaimed(ctx, now)
+
 	jobs, err := n.Store.Jobs().Due(ctx, now, 200)
 	if err != nil {
-		log.Printf("jobs.Due error: %v", err)
+		logging.FromContext(ctx).Error("jobs.Due failed", logging.Err(err))
 		return
 	}
+	metrics.JobsDue.Add(float64(len(jobs)))
+
+	settings := map[int64]storage.ChatSettings{}
+	chatSettings := func(chatID int64) storage.ChatSettings {
+		if cs, ok := settings[chatID]; ok {
+			return cs
+		}
+		cs, _ := n.Store.ChatSettings().Get(ctx, chatID)
+		settings[chatID] = cs
+		return cs
+	}
+	chatLoc := func(chatID int64) *time.Location {
+		return storage.LoadUserLocation(chatSettings(chatID).TimeZone)
+	}
+
+	missed := map[int64][]storage.Job{}
+	silentChats := map[int64]bool{}
+	var missedChats []int64
 	for _, j := range jobs {
-		msg := tgbotapi.NewMessage(j.ChatID, "Напоминание: "+j.Message)
-		if _, err := n.Bot.Send(msg); err != nil {
-			log.Printf("send reminder error: %v", err)
+		if n.Store.ForgottenAfter(j.ChatID, now) {
+			continue
+		}
+		if j.NagSeq > 0 && acknowledged(j) {
+			n.skip(ctx, j, "acknowledged")
+			continue
+		}
+		cs := chatSettings(j.ChatID)
+		if cs.Holidays == storage.HolidaysAll && j.NagSeq == 0 && j.ReminderRule != nil && *j.ReminderRule != "" {
+			if occ := j.Occurrence(); occ != nil && holidays.DayOff(occ.In(chatLoc(j.ChatID))) {
+				n.skip(ctx, j, "holiday")
+				continue
+			}
+		}
+		silent := false
+		if until, quiet := quietUntil(cs.QuietFrom, cs.QuietTo, chatLoc(j.ChatID), now); quiet {
+			if cs.QuietMode != storage.QuietSilent {
+				if err := n.Store.Jobs().Defer(ctx, j.ID, until.UTC()); err != nil {
+					jobLogger(ctx, j).Error("jobs.Defer failed", logging.Err(err))
+				}
+				continue
+			}
+			silent = true
+		}
+		text := reminderText(j)
+		if lateBy := now.Sub(jobDueAt(j)); lateBy > n.MaxLate {
+			switch n.CatchUp {
+			case CatchUpRecent:
+				n.skip(ctx, j, fmt.Sprintf("skipped: late by %v", lateBy.Round(time.Minute)))
+				continue
+			case CatchUpDigest:
+				if silent {
+					silentChats[j.ChatID] = true
+				}
+				if _, ok := missed[j.ChatID]; !ok {
+					missedChats = append(missedChats, j.ChatID)
+				}
+				missed[j.ChatID] = append(missed[j.ChatID], j)
+				continue
+			default:
+				text = fmt.Sprintf("%s\nС опозданием: должно было прийти %s",
+					text, j.ReportTime.In(chatLoc(j.ChatID)).Format("Mon, 02 Jan 15:04"))
+			}
+		}
+		msg := tgbotapi.NewMessage(j.ChatID, text)
+		msg.DisableNotification = silent
+		addressee(&msg, j)
+		if kb, ok := reminderKeyboard(j); ok {
+			msg.ReplyMarkup = kb
+		}
+		if j.AssigneeID != nil && j.AssigneeDM {
+			msg = toAssignee(msg, j)
+		}
+		n.deliver(ctx, msg, j)
+	}
+
+	for _, chatID := range missedChats {
+		loc := chatLoc(chatID)
+		var b strings.Builder
+		b.WriteString("Пока я был офлайн, вы пропустили:\n")
+		for _, j := range missed[chatID] {
+			fmt.Fprintf(&b, "• %s — %s\n", j.ReportTime.In(loc).Format("Mon, 02 Jan 15:04"), j.Message)
+		}
+		msg := tgbotapi.NewMessage(chatID, b.String())
+		msg.DisableNotification = silentChats[chatID]
+		if n.deliver(ctx, msg, missed[chatID]...) {
+			metrics.DigestsSent.WithLabelValues("missed").Inc()
+		}
+	}
+}
+
+func jobLogger(ctx context.Context, j storage.Job) *slog.Logger {
+	return logging.FromContext(ctx).With("job_id", j.ID, "chat_id", j.ChatID, "reminder_id", j.ReminderID)
+}
+
+// jobDueAt — момент, к которому job должен был уйти: время очередной
+// попытки, если она назначалась, иначе исходное время отправки.
+func jobDueAt(j storage.Job) time.Time {
+	if j.NextAttemptAt != nil {
+		return *j.NextAttemptAt
+	}
+	return j.ReportTime
+}
+
+// confirmClaimed разбирает job'ы, которые захвачены, но не отмечены
+// отправленными. Если сообщение по job'у ушло в этом процессе, дописывает
+// sent_at, с растущими паузами, пока запись не проходит. Иначе (бот упал
+// между захватом и отправкой) неизвестно, дошло ли сообщение: повторно его
+// не отправляем, а переводим job в failed с пометкой, чтобы его было видно
+// в /jobs failed и можно было вернуть в очередь.
+func (n *Notifier) confirmClaimed(ctx context.Context, now time.Time) {
+	jobs, err := n.Store.Jobs().Claimed(ctx, now, 200)
+	if err != nil {
+		logging.FromContext(ctx).Error("jobs.Claimed failed", logging.Err(err))
+		return
+	}
+	for _, j := range jobs {
+		n.mu.Lock()
+		sent := n.delivered[j.ID]
+		n.mu.Unlock()
+		if !sent {
+			if err := n.Store.Jobs().RecordFailure(ctx, j.ID, "unconfirmed delivery", nil); err != nil {
+				jobLogger(ctx, j).Error("jobs.RecordFailure failed", logging.Err(err))
+				continue
+			}
+			metrics.JobsFailed.WithLabelValues("dead").Inc()
+			jobLogger(ctx, j).Warn("claimed job was not confirmed, moved to dead letter")
+			continue
+		}
+		if err := n.Store.Jobs().MarkSent(ctx, j.ID); err != nil {
+			delay := confirmDelay
+			if j.ClaimedAt != nil {
+				delay = max(now.Sub(*j.ClaimedAt), confirmDelay)
+			}
+			delay = min(delay, retryMaxDelay)
+			jobLogger(ctx, j).Error("jobs.MarkSent retry failed", "retry_in", delay, logging.Err(err))
+			if err := n.Store.Jobs().Defer(ctx, j.ID, now.Add(delay)); err != nil {
+				jobLogger(ctx, j).Error("jobs.Defer failed", logging.Err(err))
+			}
+			continue
+		}
+		n.mu.Lock()
+		delete(n.delivered, j.ID)
+		n.mu.Unlock()
+		n.confirmSent(ctx, j)
+	}
+}
+
+// deliver захватывает job'ы, отправляет сообщение и закрывает их. Job'ы,
+// которые захватить не удалось, не отправляются. При ошибке отправки каждому
+// засчитывается неудачная попытка, а захват снимается.
+func (n *Notifier) deliver(ctx context.Context, msg tgbotapi.MessageConfig, jobs ...storage.Job) bool {
+	confirmBy := time.Now().UTC().Add(confirmDelay)
+	var claimed []storage.Job
+	for _, j := range jobs {
+		ok, err := n.Store.Jobs().Claim(ctx, j.ID, confirmBy)
+		if err != nil {
+			jobLogger(ctx, j).Error("jobs.Claim failed", logging.Err(err))
+			continue
+		}
+		if ok {
+			claimed = append(claimed, j)
+		}
+	}
+	if len(claimed) == 0 {
+		return false
+	}
+	jobs = claimed
+
+	if _, err := n.Bot.Send(msg); err != nil {
+		for _, j := range jobs {
+			if msg.ChatID != j.ChatID && isForbidden(err) && n.dmClosed(ctx, j) {
+				continue
+			}
+			n.recordFailure(ctx, j, err)
+		}
+		return false
+	}
+	for _, j := range jobs {
+		metrics.JobsSent.Inc()
+		metrics.JobLag.Observe(time.Since(j.ReportTime).Seconds())
+		if err := n.Store.Jobs().MarkSent(ctx, j.ID); err != nil {
+			// захват остался — confirmClaimed допишет отправку позже
+			jobLogger(ctx, j).Error("jobs.MarkSent failed", logging.Err(err))
+			n.mu.Lock()
+			n.delivered[j.ID] = true
+			n.mu.Unlock()
 			continue
 		}
-		_ = n.Store.Jobs().MarkSent(context.Background(), j.ID)
-		if j.ReminderRule == nil || *j.ReminderRule == "" {
-			_ = n.Store.Reminders().DeleteIfNoPending(ctx, j.ReminderID)
+		n.confirmSent(ctx, j)
+	}
+	return true
+}
+
+// dmClosed — исполнитель заблокировал бота: больше не пишем ему в личку, а
+// job сразу возвращаем в очередь, чтобы он ушёл упоминанием в группу.
+func (n *Notifier) dmClosed(ctx context.Context, j storage.Job) bool {
+	if err := n.Store.Users().SetPrivateChat(ctx, *j.AssigneeID, false); err != nil {
+		jobLogger(ctx, j).Error("users.SetPrivateChat failed", logging.Err(err))
+		return false
+	}
+	seenUsers.Delete(*j.AssigneeID)
+	if err := n.Store.Jobs().Release(ctx, j.ID, time.Now().UTC()); err != nil {
+		jobLogger(ctx, j).Error("jobs.Release failed", logging.Err(err))
+	}
+	jobLogger(ctx, j).Info("assignee blocked the bot, falling back to group")
+	return true
+}
+
+func isForbidden(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 403
+}
+
+// confirmSent вызывается, когда отправка job'а сохранена в БД.
+func (n *Notifier) confirmSent(ctx context.Context, j storage.Job) {
+	if j.Persistent {
+		n.scheduleNag(ctx, j)
+	}
+	n.afterSent(ctx, j)
+}
+
+// acknowledged — «Готово» нажато для текущего вхождения. У повторяющегося
+// напоминания отметка прошлого вхождения не считается: она должна быть
+// поставлена не раньше первого смещения этого.
+func acknowledged(j storage.Job) bool {
+	if j.AcknowledgedAt == nil {
+		return false
+	}
+	occ := j.Occurrence()
+	if j.ReminderRule == nil || *j.ReminderRule == "" || occ == nil {
+		return true
+	}
+	lead := 0
+	for _, off := range j.ReminderOffsets {
+		lead = max(lead, off)
+	}
+	return j.AcknowledgedAt.After(occ.Add(-time.Duration(lead) * time.Minute))
+}
+
+// scheduleNag ставит следующий повтор настойчивого напоминания. Цепочка
+// повторов начинается после последнего основного job'а вхождения, если
+// «Готово» ещё не нажато.
+func (n *Notifier) scheduleNag(ctx context.Context, j storage.Job) {
+	if acknowledged(j) {
+		return
+	}
+	if j.NagSeq == 0 {
+		pending, err := n.Store.Jobs().PendingCount(ctx, j.ReminderID)
+		if err != nil {
+			jobLogger(ctx, j).Error("jobs.PendingCount failed", logging.Err(err))
+			return
 		}
+		if pending > 0 {
+			return
+		}
+	}
+	maxNags, every := j.NagMax, j.NagInterval
+	if maxNags <= 0 {
+		maxNags = storage.DefaultNagMax
+	}
+	if every <= 0 {
+		every = storage.DefaultNagInterval
+	}
+	if j.NagSeq >= maxNags {
+		return
+	}
+	at := time.Now().UTC().Add(time.Duration(every) * time.Minute)
+	if err := n.Store.Jobs().CreateNag(ctx, j.ReminderID, at, j.NagSeq+1); err != nil {
+		jobLogger(ctx, j).Error("jobs.CreateNag failed", logging.Err(err))
+	}
+}
+
+// purgeHistory удаляет из истории напоминания, ушедшие в архив раньше,
+// чем Retention назад, и прошедшие исключения расписания.
+func (n *Notifier) purgeHistory(ctx context.Context) {
+	exs, err := n.Store.Exceptions().PurgeBefore(ctx, time.Now().Add(-exceptionsKeep))
+	if err != nil {
+		logging.FromContext(ctx).Error("exceptions purge failed", logging.Err(err))
+	} else if exs > 0 {
+		logging.FromContext(ctx).Info("schedule exceptions purged", "exceptions", exs)
+	}
 
-		if j.ReminderRule != nil && *j.ReminderRule != "" {
-			cs, _ := n.Store.ChatSettings().Get(context.Background(), j.ChatID)
-			next := storage.NextFromWeeklyRRULE(*j.ReminderRule, cs.TimeZone, time.Now())
-			nextUTC := next.UTC()
-			_ = n.Store.Reminders().UpdateNextReport(context.Background(), j.ReminderID, &nextUTC)
-			fireUTC := nextUTC.Add(-time.Duration(j.ReminderTime) * time.Minute)
-			_ = n.Store.Jobs().Create(context.Background(), j.ReminderID, fireUTC)
+	if n.Retention <= 0 {
+		return
+	}
+	purged, err := n.Store.Reminders().PurgeArchived(ctx, time.Now().Add(-n.Retention))
+	if err != nil {
+		logging.FromContext(ctx).Error("history purge failed", logging.Err(err))
+		return
+	}
+	if purged > 0 {
+		logging.FromContext(ctx).Info("history purged", "reminders", purged)
+	}
+}
+
+func (n *Notifier) skip(ctx context.Context, j storage.Job, reason string) {
+	if err := n.Store.Jobs().MarkSkipped(ctx, j.ID, reason); err != nil {
+		jobLogger(ctx, j).Error("jobs.MarkSkipped failed", logging.Err(err))
+		return
+	}
+	jobLogger(ctx, j).Info("job skipped", "reason", reason)
+	n.afterSent(ctx, j)
+}
+
+// afterSent переносит в историю отработавшее (или пропущенное) разовое напоминание или,
+// когда по текущему вхождению повторяющегося не осталось job'ов, планирует
+// все смещения следующего.
+func (n *Notifier) afterSent(ctx context.Context, j storage.Job) {
+	if j.ReminderRule == nil || *j.ReminderRule == "" {
+		_ = n.Store.Reminders().ArchiveIfNoPending(ctx, j.ReminderID)
+		return
+	}
+	if j.NagSeq > 0 {
+		return
+	}
+	pending, err := n.Store.Jobs().PendingCount(ctx, j.ReminderID)
+	if err != nil {
+		jobLogger(ctx, j).Error("jobs.PendingCount failed", logging.Err(err))
+		return
+	}
+	if pending > 0 {
+		return
+	}
+	cs, _ := n.Store.ChatSettings().Get(ctx, j.ChatID)
+	from := time.Now()
+	if occ := j.Occurrence(); occ != nil && occ.After(from) {
+		from = *occ
+	}
+	nextUTC := storage.NextFromWeeklyRRULE(*j.ReminderRule, cs.TimeZone, from).UTC()
+	_ = n.Store.Reminders().UpdateNextReport(ctx, j.ReminderID, &nextUTC)
+	if err := n.Store.Jobs().CreateForEvent(ctx, j.ReminderID, nextUTC, j.ReminderOffsets, time.Now().UTC()); err != nil {
+		jobLogger(ctx, j).Error("jobs.CreateForEvent failed", logging.Err(err))
+	}
+	// новое вхождение — отметка «Готово» прошлого к нему не относится
+	if j.AcknowledgedAt != nil {
+		if err := n.Store.Reminders().ClearAck(ctx, j.ReminderID); err != nil {
+			jobLogger(ctx, j).Error("reminders.ClearAck failed", logging.Err(err))
+		}
+	}
+}
+
+// reminderText — текст напоминания с пометкой, сколько осталось до события.
+func reminderText(j storage.Job) string {
+	if j.NagSeq > 0 {
+		maxNags := j.NagMax
+		if maxNags <= 0 {
+			maxNags = storage.DefaultNagMax
+		}
+		return fmt.Sprintf("🔁 Напоминание (повтор %d/%d): %s", j.NagSeq, maxNags, j.Message)
+	}
+	text := "Напоминание: " + j.Message
+	if occ := j.Occurrence(); occ != nil {
+		if d := occ.Sub(j.ReportTime).Round(time.Minute); d >= time.Minute {
+			text += " (через " + formatLead(int(d/time.Minute)) + ")"
 		}
 	}
+	return text
 }
 
-func (n *Notifier) processDailyDigests() {
-	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
+// recordFailure считает неудачную попытку и назначает следующую с
+// экспоненциальной задержкой. Постоянные ошибки (бот заблокирован, чат не
+// найден) и исчерпанный лимит попыток переводят job в failed.
+func (n *Notifier) recordFailure(ctx context.Context, j storage.Job, sendErr error) {
+	attempt := j.Attempts + 1
+	delay, permanent := retryDelay(sendErr, attempt)
+
+	var retryAt *time.Time
+	if !permanent && attempt < n.MaxAttempts {
+		t := time.Now().UTC().Add(delay)
+		retryAt = &t
+	}
+	if err := n.Store.Jobs().RecordFailure(ctx, j.ID, sendErr.Error(), retryAt); err != nil {
+		jobLogger(ctx, j).Error("jobs.RecordFailure failed", logging.Err(err))
+	}
+	if retryAt == nil {
+		metrics.JobsFailed.WithLabelValues("dead").Inc()
+		jobLogger(ctx, j).Error("send reminder failed permanently", "attempt", attempt, logging.Err(sendErr))
+		return
+	}
+	metrics.JobsFailed.WithLabelValues("retry").Inc()
+	jobLogger(ctx, j).Warn("send reminder failed", "attempt", attempt, "retry_in", delay, logging.Err(sendErr))
+}
+
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 400, 403:
+			return 0, true
+		case 429:
+			if apiErr.RetryAfter > 0 {
+				return time.Duration(apiErr.RetryAfter) * time.Second, false
+			}
+		}
+	}
+	d := retryBaseDelay << (attempt - 1)
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d, false
+}
+
+func (n *Notifier) processDailyDigests(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
 	defer cancel()
 
 	chats, err := n.Store.ChatSettings().ChatsToDigestNow(ctx)
 	if err != nil {
-		log.Printf("digest query error: %v", err)
+		logging.FromContext(ctx).Error("digest query failed", logging.Err(err))
 		return
 	}
 
@@ -113,36 +604,55 @@ func (n *Notifier) processDailyDigests() {
 
 		items, err := n.Store.Reminders().GetUpcoming(ctx, ch.ChatID, sUTC, &eUTC, 100)
 		if err != nil {
-			log.Printf("digest fetch error chat=%d: %v", ch.ChatID, err)
+			logging.FromContext(ctx).Error("digest fetch failed", "chat_id", ch.ChatID, logging.Err(err))
 			continue
 		}
 
+		cs := storage.ChatSettings{ChatID: ch.ChatID, TimeZone: ch.TimeZone, Holidays: ch.Holidays}
+		entries, err := loadDay(ctx, n.Store, cs, ch.ChatID, start)
+		if err != nil {
+			logging.FromContext(ctx).Error("digest timetable fetch failed", "chat_id", ch.ChatID, logging.Err(err))
+		}
+
 		var b strings.Builder
 		b.WriteString("🗓 Завтра:\n")
-		if len(items) == 0 {
+		if holidaysOn(cs) && holidays.DayOff(start) {
+			b.WriteString("🎉 праздничный день\n")
+		}
+		if len(items) == 0 && len(entries) == 0 {
 			b.WriteString("— ничего не запланировано\n")
-		} else {
-			for _, r := range items {
-				when := "—"
-				if r.EventTime != nil {
-					when = r.EventTime.In(loc).Format("Mon, 02 Jan 15:04")
-				} else if r.NextReport != nil {
-					when = r.NextReport.In(loc).Format("Mon, 02 Jan 15:04")
-				}
-				fmt.Fprintf(&b, "• %s — %s\n", when, r.Message)
+		}
+		for _, r := range items {
+			when := "—"
+			if r.EventTime != nil {
+				when = r.EventTime.In(loc).Format("Mon, 02 Jan 15:04")
+			} else if r.NextReport != nil {
+				when = r.NextReport.In(loc).Format("Mon, 02 Jan 15:04")
+			}
+			fmt.Fprintf(&b, "• %s — %s\n", when, r.Message)
+		}
+		if len(entries) > 0 {
+			b.WriteString("\n📅 Расписание:\n")
+			for _, e := range entries {
+				b.WriteString("• " + describeEntry(e) + "\n")
 			}
 		}
 
-		if _, err := n.Bot.Send(tgbotapi.NewMessage(ch.ChatID, b.String())); err != nil {
-			log.Printf("digest send error chat=%d: %v", ch.ChatID, err)
+		// Отчёт привязан ко времени, переносить его бессмысленно: в тихие часы
+		// он приходит без звука при любом режиме.
+		msg := tgbotapi.NewMessage(ch.ChatID, b.String())
+		_, msg.DisableNotification = quietUntil(ch.QuietFrom, ch.QuietTo, loc, nowLocal)
+		if _, err := n.Bot.Send(msg); err != nil {
+			logging.FromContext(ctx).Error("digest send failed", "chat_id", ch.ChatID, logging.Err(err))
 			continue
 		}
 
 		n.mu.Lock()
 		n.lastDigest[ch.ChatID] = target
 		n.mu.Unlock()
+		metrics.DigestsSent.WithLabelValues("daily").Inc()
 
-		log.Printf("digest sent chat=%d tz=%s at %s", ch.ChatID, ch.TimeZone, nowLocal.Format(time.RFC3339))
+		logging.FromContext(ctx).Info("digest sent", "chat_id", ch.ChatID, "tz", ch.TimeZone, "local_time", nowLocal.Format(time.RFC3339))
 	}
 }
 
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"sync"
+	"time"
+)
+
+// pendingSet хранит то, что ждёт нажатия кнопки: превью импорта, запрос
+// подтверждения, текст поиска для листания. В callback data уходит только
+// nonce записи, поэтому в одном чате может ждать несколько записей сразу и
+// новая не перетирает чужую. Записи старше ttl считаются устаревшими.
+//
+// Nonce случайный, а не счётчик: счётчик после перезапуска начался бы
+// заново, и кнопка старого сообщения попала бы в новую запись.
+type pendingSet[T any] struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	m   map[string]*pendingItem[T]
+}
+
+type pendingItem[T any] struct {
+	chatID  int64
+	created time.Time
+	val     T
+}
+
+func newPendingSet[T any](ttl time.Duration) *pendingSet[T] {
+	return &pendingSet[T]{ttl: ttl, m: map[string]*pendingItem[T]{}}
+}
+
+var nonceEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newNonce — 8 случайных байт в base32: 13 символов, без «:», так что
+// помещается в callback data вместе с остальными полями.
+func newNonce() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:]) // crypto/rand.Read не возвращает ошибок
+	return nonceEncoding.EncodeToString(b[:])
+}
+
+// put сохраняет запись чата и возвращает её nonce. Заодно выбрасывает
+// устаревшие записи, чтобы брошенные превью не копились.
+func (s *pendingSet[T]) put(chatID int64, v T) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for n, it := range s.m {
+		if now.Sub(it.created) > s.ttl {
+			delete(s.m, n)
+		}
+	}
+	n := newNonce()
+	for s.m[n] != nil {
+		n = newNonce()
+	}
+	s.m[n] = &pendingItem[T]{chatID: chatID, created: now, val: v}
+	return n
+}
+
+// get возвращает запись по nonce из callback data, если она из этого чата
+// и ещё не устарела. Запись остаётся на месте.
+func (s *pendingSet[T]) get(chatID int64, nonce string) (T, bool) {
+	var zero T
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it := s.m[nonce]
+	if it == nil || it.chatID != chatID || time.Since(it.created) > s.ttl {
+		return zero, false
+	}
+	return it.val, true
+}
+
+// remove забирает запись. false — её уже забрал параллельный нажим кнопки
+// или dropPending: тогда обрабатывать нечего.
+func (s *pendingSet[T]) remove(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m[nonce] == nil {
+		return false
+	}
+	delete(s.m, nonce)
+	return true
+}
+
+// drop забывает все записи чата.
+func (s *pendingSet[T]) drop(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n, it := range s.m {
+		if it.chatID == chatID {
+			delete(s.m, n)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// quietUntil проверяет, попадает ли now в окно тихих часов from–to (локальное
+// время loc; окно может переходить через полночь). Если попадает, возвращает
+// конец окна.
+func quietUntil(from, to *time.Time, loc *time.Location, now time.Time) (time.Time, bool) {
+	if from == nil || to == nil {
+		return time.Time{}, false
+	}
+	local := now.In(loc)
+	cur := local.Hour()*60 + local.Minute()
+	f := from.Hour()*60 + from.Minute()
+	e := to.Hour()*60 + to.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), to.Hour(), to.Minute(), 0, 0, loc)
+
+	switch {
+	case f == e:
+		return time.Time{}, false
+	case f < e:
+		if cur >= f && cur < e {
+			return endToday, true
+		}
+	default:
+		if cur >= f {
+			return endToday.AddDate(0, 0, 1), true
+		}
+		if cur < e {
+			return endToday, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func HandleQuiet(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	usage := "Пример:\n /quiet 23:00-08:00 (ночью напоминания переносятся на конец окна)\n /quiet 23:00-08:00 silent (приходят, но без звука)\n /quiet off"
+	parts := strings.Fields(arg)
+	if len(parts) == 0 {
+		cs, _ := store.ChatSettings().Get(ctx, chatID)
+		if cs.QuietFrom == nil || cs.QuietTo == nil {
+			Reply(bot, chatID, "Тихие часы не заданы.\n"+usage)
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("Тихие часы: %s–%s, режим %s",
+			cs.QuietFrom.Format("15:04"), cs.QuietTo.Format("15:04"), cs.QuietMode))
+		return
+	}
+
+	if strings.ToLower(parts[0]) == "off" {
+		if err := store.ChatSettings().UpsertQuiet(ctx, chatID, nil, nil, storage.QuietDelay); err != nil {
+			logging.FromContext(ctx).Error("save quiet hours failed", logging.Err(err))
+			Reply(bot, chatID, "Не смог сохранить тихие часы")
+			return
+		}
+		Reply(bot, chatID, "Тихие часы выключены")
+		return
+	}
+
+	from, to, err := timeparse.ParseTimeRange(parts[0])
+	if err != nil || to == nil {
+		Reply(bot, chatID, usage)
+		return
+	}
+	mode := storage.QuietDelay
+	if len(parts) > 1 {
+		switch strings.ToLower(parts[1]) {
+		case "delay", "перенос":
+			mode = storage.QuietDelay
+		case "silent", "тихо":
+			mode = storage.QuietSilent
+		default:
+			Reply(bot, chatID, usage)
+			return
+		}
+	}
+	if err := store.ChatSettings().UpsertQuiet(ctx, chatID, &from, to, mode); err != nil {
+		logging.FromContext(ctx).Error("save quiet hours failed", logging.Err(err))
+		Reply(bot, chatID, "Не смог сохранить тихие часы")
+		return
+	}
+	how := "напоминания будут переноситься на конец окна"
+	if mode == storage.QuietSilent {
+		how = "напоминания будут приходить без звука"
+	}
+	Reply(bot, chatID, fmt.Sprintf("Тихие часы %s–%s: %s", from.Format("15:04"), to.Format("15:04"), how))
+}
@@ -0,0 +1,300 @@
+package telegram
+
+import (
+	"TelegramBot/internal/holidays"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var weekdayShort = []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+
+// defaultEntryLength — сколько считать занятым после записи без конца.
+const defaultEntryLength = 60
+
+// timetableOwner — чьи записи меняет /timetable set|clear: в группе у
+// каждого участника своё расписание, в личке — общее расписание чата.
+func timetableOwner(owner *tgbotapi.User) (*int64, string) {
+	if owner == nil {
+		return nil, ""
+	}
+	id := owner.ID
+	return &id, authorName(owner)
+}
+
+// interval — отрезок дня в минутах от полуночи, [from, to).
+type interval struct{ from, to int }
+
+func (iv interval) String() string {
+	return fmt.Sprintf("%02d:%02d–%02d:%02d", iv.from/60, iv.from%60, iv.to/60, iv.to%60)
+}
+
+func minutesOf(t time.Time) int { return t.Hour()*60 + t.Minute() }
+
+// busyInterval — время, которое занимает запись. Запись без конца занимает
+// defaultEntryLength минут, запись «через полночь» — до конца дня.
+func busyInterval(e storage.WeeklyEntry) interval {
+	from := minutesOf(e.StartTime)
+	to := from + defaultEntryLength
+	if e.EndTime != nil {
+		to = minutesOf(*e.EndTime)
+	}
+	if to <= from || to > 24*60 {
+		to = 24 * 60
+	}
+	return interval{from, to}
+}
+
+// freeSlots возвращает промежутки окна window, не занятые ни одной из
+// записей, — общее свободное время всех участников.
+func freeSlots(entries []storage.WeeklyEntry, window interval) []interval {
+	busy := make([]interval, 0, len(entries))
+	for _, e := range entries {
+		busy = append(busy, busyInterval(e))
+	}
+	return gaps(busy, window)
+}
+
+// gaps возвращает промежутки окна, не покрытые ни одним из интервалов busy.
+func gaps(busy []interval, window interval) []interval {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].from < busy[j].from })
+
+	var out []interval
+	cur := window.from
+	for _, b := range busy {
+		if b.to <= cur {
+			continue
+		}
+		if b.from >= window.to {
+			break
+		}
+		if b.from > cur {
+			out = append(out, interval{cur, b.from})
+		}
+		cur = b.to
+	}
+	if cur < window.to {
+		out = append(out, interval{cur, window.to})
+	}
+	return out
+}
+
+// parseFreeArgs разбирает «Пн [09:00-18:00]». Окно по умолчанию — 09:00–21:00.
+func parseFreeArgs(args []string) (int, interval, error) {
+	window := interval{9 * 60, 21 * 60}
+	if len(args) == 0 {
+		return 0, window, fmt.Errorf("no weekday")
+	}
+	wd, ok := timeparse.ParseWeekday(args[0])
+	if !ok {
+		return 0, window, fmt.Errorf("bad weekday: %q", args[0])
+	}
+	if len(args) > 1 {
+		st, et, err := timeparse.ParseTimeRange(args[1])
+		if err != nil {
+			return 0, window, err
+		}
+		if et == nil || !et.After(st) {
+			return 0, window, fmt.Errorf("bad window: %q", args[1])
+		}
+		window = interval{minutesOf(st), minutesOf(*et)}
+	}
+	return wd, window, nil
+}
+
+// ownersBusy — кто из участников занят в день wd, для ответа /timetable free.
+func ownersBusy(entries []storage.WeeklyEntry) string {
+	seen := map[string]bool{}
+	var names []string
+	for _, e := range entries {
+		name := e.OwnerName
+		if name == "" {
+			name = "общее"
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectEntries выбирает записи дня по «#n» (номер из /timetable show) или
+// по времени начала («10», «10:30»).
+func selectEntries(entries []storage.WeeklyEntry, sel string) ([]storage.WeeklyEntry, error) {
+	if n, ok := strings.CutPrefix(sel, "#"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 || i > len(entries) {
+			return nil, fmt.Errorf("no entry %s", sel)
+		}
+		return entries[i-1 : i], nil
+	}
+	t, err := timeparse.ParseHM(sel)
+	if err != nil {
+		return nil, err
+	}
+	var out []storage.WeeklyEntry
+	for _, e := range entries {
+		if minutesOf(e.StartTime) == minutesOf(t) {
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no entry at %s", sel)
+	}
+	return out, nil
+}
+
+func sameOwner(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// weekdaysOf — «Пн, Ср» для ответа о заменённых днях.
+func weekdaysOf(entries []storage.WeeklyEntry) string {
+	var seen [8]bool
+	var names []string
+	for _, e := range entries {
+		if !seen[e.Weekday] {
+			seen[e.Weekday] = true
+			names = append(names, weekdayShort[e.Weekday-1])
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+var parityLabels = map[int]string{storage.ParityOdd: "числ.", storage.ParityEven: "знам."}
+
+// entryDuration — длительность записи с концом; запись через полночь
+// (22:00–01:00) длится до следующего дня.
+func entryDuration(e storage.WeeklyEntry) time.Duration {
+	d := e.EndTime.Sub(e.StartTime)
+	if d <= 0 {
+		d += 24 * time.Hour
+	}
+	return d
+}
+
+// describeEntry — строка записи расписания без номера: время, название,
+// чередование, период, место, заметка и владелец.
+func describeEntry(e storage.WeeklyEntry) string {
+	var b strings.Builder
+	b.WriteString(e.StartTime.Format("15:04"))
+	if e.EndTime != nil {
+		b.WriteString("–" + e.EndTime.Format("15:04"))
+	}
+	b.WriteString(" — " + e.Title)
+	var marks []string
+	if l, ok := parityLabels[e.Parity]; ok {
+		marks = append(marks, l)
+	}
+	if e.ValidFrom != nil {
+		marks = append(marks, "с "+e.ValidFrom.Format("02.01.2006"))
+	}
+	if e.ValidTo != nil {
+		marks = append(marks, "по "+e.ValidTo.Format("02.01.2006"))
+	}
+	if len(marks) > 0 {
+		b.WriteString(" [" + strings.Join(marks, ", ") + "]")
+	}
+	if e.Location != "" {
+		b.WriteString(" @ " + e.Location)
+	}
+	if e.OwnerName != "" {
+		b.WriteString(" (" + e.OwnerName + ")")
+	}
+	if e.Notes != "" {
+		b.WriteString("\n      " + e.Notes)
+	}
+	return b.String()
+}
+
+// mondayOf — полночь понедельника недели, в которую попадает t.
+func mondayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).
+		AddDate(0, 0, 1-storage.ISOWeekday(t))
+}
+
+// nextWeekday — ближайшая дата с днём недели wd, начиная с сегодня.
+func nextWeekday(now time.Time, wd int) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return day.AddDate(0, 0, (wd-storage.ISOWeekday(now)+7)%7)
+}
+
+// activeOn оставляет записи, действующие в день day.
+func activeOn(entries []storage.WeeklyEntry, day time.Time) []storage.WeeklyEntry {
+	var out []storage.WeeklyEntry
+	for _, e := range entries {
+		if e.ActiveOn(day) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// holidaysOn — скрывать ли расписание в праздники.
+func holidaysOn(cs storage.ChatSettings) bool {
+	return cs.Holidays == storage.HolidaysTimetable || cs.Holidays == storage.HolidaysAll
+}
+
+// cancelled сообщает, отменена ли запись e исключением на день day.
+func cancelled(e storage.WeeklyEntry, exs []storage.ScheduleException, day time.Time) bool {
+	for _, x := range exs {
+		if sameDay(x.Date, day) && x.Cancels(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// addedOn — разовые занятия на день day.
+func addedOn(exs []storage.ScheduleException, day time.Time) []storage.ScheduleException {
+	var out []storage.ScheduleException
+	for _, x := range exs {
+		if x.Kind == storage.ExceptionAdd && sameDay(x.Date, day) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// effectiveEntries — что на самом деле идёт в день day: записи недели с
+// учётом периода и чётности, без отменённых и, если включён календарь,
+// без праздников, плюс разовые занятия.
+func effectiveEntries(entries []storage.WeeklyEntry, exs []storage.ScheduleException, day time.Time, skipHolidays bool) []storage.WeeklyEntry {
+	var out []storage.WeeklyEntry
+	if !skipHolidays || !holidays.DayOff(day) {
+		for _, e := range activeOn(entries, day) {
+			if !cancelled(e, exs, day) {
+				out = append(out, e)
+			}
+		}
+	}
+	for _, x := range addedOn(exs, day) {
+		out = append(out, x.Entry())
+	}
+	sort.SliceStable(out, func(i, j int) bool { return minutesOf(out[i].StartTime) < minutesOf(out[j].StartTime) })
+	return out
+}
+
+// loadDay читает расписание чата на конкретную дату.
+func loadDay(ctx context.Context, store *storage.Storage, cs storage.ChatSettings, chatID int64, day time.Time) ([]storage.WeeklyEntry, error) {
+	entries, err := store.Schedule().ListForWeekday(ctx, chatID, storage.ISOWeekday(day))
+	if err != nil {
+		return nil, err
+	}
+	exs, err := store.Exceptions().Between(ctx, chatID, day, day)
+	if err != nil {
+		return nil, err
+	}
+	return effectiveEntries(entries, exs, day, holidaysOn(cs)), nil
+}
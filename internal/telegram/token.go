@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleToken выдаёт токен REST API для чата (/token) или отзывает все
+// выданные ранее (/token revoke). В группе токеном управляет только
+// администратор, а сам токен приходит ему в личку.
+func HandleToken(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, selfURL string, m *tgbotapi.Message, arg string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	chatID := m.Chat.ID
+	group := isGroup(m.Chat)
+	if group && (m.From == nil || !isChatAdmin(ctx, bot, chatID, m.From.ID)) {
+		Reply(bot, chatID, "Токенами API группы управляет только администратор")
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "":
+		token, err := store.Tokens().Issue(ctx, chatID)
+		if err != nil {
+			logging.FromContext(ctx).Error("issue token failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось выпустить токен")
+			return
+		}
+		text := fmt.Sprintf("Токен API (показываю один раз, храни в секрете):\n%s\n\nПример:\ncurl -H \"Authorization: Bearer %s\" %s/api/v1/chats/%d/reminders\n\nОтозвать все токены: /token revoke",
+			token, token, strings.TrimRight(selfURL, "/"), chatID)
+		if !group {
+			Reply(bot, chatID, text)
+			return
+		}
+		if m.Chat.Title != "" {
+			text = "Группа «" + m.Chat.Title + "».\n" + text
+		}
+		if _, err := bot.Send(tgbotapi.NewMessage(m.From.ID, text)); err != nil {
+			logging.FromContext(ctx).Warn("token dm failed", logging.Err(err))
+			if err := store.Tokens().Revoke(ctx, token); err != nil {
+				logging.FromContext(ctx).Error("revoke undelivered token failed", logging.Err(err))
+			}
+			Reply(bot, chatID, "Не могу написать тебе в личку: открой чат со мной, нажми «Start» и повтори /token")
+			return
+		}
+		Reply(bot, chatID, "Отправил токен в личные сообщения")
+
+	case "revoke":
+		n, err := store.Tokens().RevokeAll(ctx, chatID)
+		if err != nil {
+			logging.FromContext(ctx).Error("revoke tokens failed", logging.Err(err))
+			Reply(bot, chatID, "Не удалось отозвать токены")
+			return
+		}
+		Reply(bot, chatID, fmt.Sprintf("Отозвано токенов: %d", n))
+
+	default:
+		Reply(bot, chatID, "Использование:\n/token — выпустить токен API\n/token revoke — отозвать все токены")
+	}
+}
@@ -0,0 +1,206 @@
+package telegram
+
+import (
+	"TelegramBot/internal/logging"
+	"TelegramBot/internal/storage"
+	"TelegramBot/internal/timeparse"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// timetableImport — разобранная таблица расписания, ожидающая подтверждения.
+type timetableImport struct {
+	ownerID   *int64
+	ownerName string
+	entries   []storage.WeeklyEntry
+}
+
+var pendingTimetables = newPendingSet[*timetableImport](importTTL)
+
+// isTableFile — файл похож на таблицу расписания: CSV, TSV или текст.
+func isTableFile(doc *tgbotapi.Document) bool {
+	name := strings.ToLower(doc.FileName)
+	for _, ext := range []string{".csv", ".tsv", ".txt"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	switch doc.MimeType {
+	case "text/csv", "text/tab-separated-values", "text/plain":
+		return true
+	}
+	return false
+}
+
+// parseTimetableText разбирает таблицу. Вставленный текст без разделителей
+// колонок можно написать и построчно в формате /timetable set.
+func parseTimetableText(data []byte) ([]storage.WeeklyEntry, error) {
+	entries, tableErr := timeparse.ParseTimetableTable(data)
+	if tableErr == nil {
+		return entries, nil
+	}
+	var out []storage.WeeklyEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		es, err := timeparse.ParseWeeklyEntries(line)
+		if err != nil || len(es) == 0 {
+			return nil, tableErr
+		}
+		out = append(out, es...)
+	}
+	if len(out) == 0 {
+		return nil, tableErr
+	}
+	return out, nil
+}
+
+// entryKey — ключ записи для сравнения старого и нового расписания.
+func entryKey(e storage.WeeklyEntry) string {
+	end := ""
+	if e.EndTime != nil {
+		end = e.EndTime.Format("15:04")
+	}
+	return fmt.Sprintf("%d|%s|%s|%s|%s|%d", e.Weekday, e.StartTime.Format("15:04"), end,
+		strings.ToLower(e.Title), strings.ToLower(e.Location), e.Parity)
+}
+
+// previewTimetableImport разбирает таблицу, показывает отличия от текущего
+// расписания владельца и ждёт подтверждения кнопкой. Подтверждённый импорт
+// заменяет всё расписание владельца.
+func previewTimetableImport(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, chatID int64, owner *tgbotapi.User, data []byte) {
+	entries, err := parseTimetableText(data)
+	if err != nil {
+		var te *timeparse.TableError
+		if errors.As(err, &te) {
+			Reply(bot, chatID, "В таблице ошибки, ничего не изменено:\n"+te.Error())
+			return
+		}
+		Reply(bot, chatID, "Не удалось прочитать таблицу: "+err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		Reply(bot, chatID, "В таблице нет ни одной записи")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ownerID, ownerName := timetableOwner(owner)
+	current, err := store.Schedule().List(ctx, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("timetable import list failed", logging.Err(err))
+		Reply(bot, chatID, "Ошибка чтения расписания")
+		return
+	}
+	old := map[string]storage.WeeklyEntry{}
+	for _, e := range current {
+		if sameOwner(e.UserID, ownerID) {
+			old[entryKey(e)] = e
+		}
+	}
+
+	var added []storage.WeeklyEntry
+	seen := map[string]bool{}
+	plan := make([]storage.WeeklyEntry, 0, len(entries))
+	for _, e := range entries {
+		k := entryKey(e)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if prev, ok := old[k]; ok {
+			// у совпавшей записи сохраняем период и заметку
+			e = prev
+		} else {
+			added = append(added, e)
+		}
+		e.OwnerName = ownerName
+		plan = append(plan, e)
+	}
+	var removed []storage.WeeklyEntry
+	for _, e := range current {
+		if sameOwner(e.UserID, ownerID) && !seen[entryKey(e)] {
+			removed = append(removed, e)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Записей в таблице: %d. Без изменений: %d, добавится: %d, удалится: %d.\n",
+		len(plan), len(plan)-len(added), len(added), len(removed))
+	shown := 0
+	list := func(mark string, es []storage.WeeklyEntry) {
+		for _, e := range es {
+			if shown == importPreviewMax {
+				return
+			}
+			shown++
+			fmt.Fprintf(&b, "%s %s %s\n", mark, weekdayShort[e.Weekday-1], describeEntry(e))
+		}
+	}
+	list("➕", added)
+	list("➖", removed)
+	if rest := len(added) + len(removed) - shown; rest > 0 {
+		fmt.Fprintf(&b, "… и ещё %d\n", rest)
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		Reply(bot, chatID, b.String()+"Расписание уже совпадает с таблицей.")
+		return
+	}
+
+	nonce := pendingTimetables.put(chatID, &timetableImport{ownerID: ownerID, ownerName: ownerName, entries: plan})
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Заменить расписание", fmt.Sprintf("tt:ok:%s", nonce)),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", fmt.Sprintf("tt:cancel:%s", nonce)),
+		),
+	)
+	if _, err := bot.Send(msg); err != nil {
+		logging.FromContext(ctx).Error("timetable import preview send failed", logging.Err(err))
+	}
+}
+
+// handleTimetableImportCallback: "ok:<nonce>" или "cancel:<nonce>". В группе
+// подтвердить может только тот, чьё расписание меняется.
+func handleTimetableImportCallback(ctx context.Context, bot *tgbotapi.BotAPI, store *storage.Storage, cq *tgbotapi.CallbackQuery, arg string) {
+	chatID := cq.Message.Chat.ID
+	action, nonce, _ := strings.Cut(arg, ":")
+
+	imp, ok := pendingTimetables.get(chatID, nonce)
+	if ok && imp.ownerID != nil && (cq.From == nil || cq.From.ID != *imp.ownerID) {
+		answerCallback(ctx, bot, cq.ID, "Это импорт чужого расписания")
+		return
+	}
+	if !ok || !pendingTimetables.remove(nonce) {
+		answerCallback(ctx, bot, cq.ID, "Импорт устарел, пришли таблицу ещё раз")
+		return
+	}
+
+	var result string
+	if action != "ok" {
+		answerCallback(ctx, bot, cq.ID, "Отменено")
+		result = "Импорт отменён"
+	} else {
+		if err := store.Schedule().Set(ctx, chatID, imp.ownerID, imp.entries); err != nil {
+			logging.FromContext(ctx).Error("timetable import failed", logging.Err(err))
+			answerCallback(ctx, bot, cq.ID, "Не удалось сохранить")
+			return
+		}
+		answerCallback(ctx, bot, cq.ID, "Готово")
+		result = fmt.Sprintf("Расписание заменено, записей: %d", len(imp.entries))
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+	if _, err := bot.Send(edit); err != nil {
+		logging.FromContext(ctx).Error("edit message failed", logging.Err(err))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"TelegramBot/internal/storage"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,35 +15,131 @@ var months = map[string]time.Month{
 }
 
 type Parsed struct {
-	Title       string
-	DueUTC      *time.Time
-	LeadMinutes int
+	Title  string
+	DueUTC *time.Time
+	// LeadOffsets — за сколько минут до события напоминать, по убыванию.
+	// Всегда содержит хотя бы одно значение (по умолчанию 30).
+	LeadOffsets []int
 	RRULE       *string
+	// Persistent — «настойчиво»: повторять до подтверждения. NagEvery —
+	// интервал повтора в минутах из «каждые N минут», 0 — по умолчанию.
+	Persistent bool
+	NagEvery   int
 }
 
-func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
-	loc := storage.LoadUserLocation(tz)
-	low := strings.ToLower(strings.TrimSpace(input))
+// \b в RE2 понимает только ASCII, поэтому границы слов для кириллицы
+// задаём явно.
+const (
+	wordStart = `(?:^|[^\p{L}\p{N}_])`
+	wordEnd   = `(?:[^\p{L}\p{N}_]|$)`
+)
+
+var (
+	reLead        = regexp.MustCompile(wordStart + `(?:и\s+)?за\s+(?:(\d+)\s*(минут[а-я]*|мин|м|час[а-я]*|ч|дн[а-я]*|день|д|сут[а-я]*|недел[а-я]*|нед)?|(минуту|час|день|сутки|неделю|полчаса))` + wordEnd)
+	reLeadAtStart = regexp.MustCompile(wordStart + `(?:и\s+)?в\s+момент(?:\s+(?:начала|события))?` + wordEnd)
+	reNag         = regexp.MustCompile(wordStart + `настойчиво(?:\s+каждые\s+(\d+)\s*(минут[а-я]*|мин|м|час[а-я]*|ч))?` + wordEnd)
+)
 
-	lead := 30
-	reLead := regexp.MustCompile(`\bза\s+(\d+)\s*(мин(?:ут[а-я]*)?|м|ч(?:ас(?:а|ов)?)?)?\b`)
-	if m := reLead.FindStringSubmatch(low); len(m) >= 2 {
-		n := toInt(m[1])
+const defaultLeadMinutes = 30
+
+// parseLeads вырезает из строки все «за N мин/ч/дней» и «в момент начала»
+// и возвращает смещения в минутах (по убыванию, без повторов).
+func parseLeads(low string) ([]int, string) {
+	var offsets []int
+	for {
+		m := reLead.FindStringSubmatchIndex(low)
+		if m == nil {
+			break
+		}
+		n := 1
 		unit := ""
-		if len(m) >= 3 {
-			unit = m[2]
+		if m[2] >= 0 {
+			n = toInt(low[m[2]:m[3]])
+			if m[4] >= 0 {
+				unit = low[m[4]:m[5]]
+			}
+		} else {
+			unit = low[m[6]:m[7]]
 		}
-		switch {
-		case unit == "" || strings.HasPrefix(unit, "м"):
-			lead = n
-		case strings.HasPrefix(unit, "ч"):
-			lead = n * 60
+		offsets = append(offsets, n*leadUnitMinutes(unit))
+		low = low[:m[0]] + " " + low[m[1]:]
+	}
+	for {
+		m := reLeadAtStart.FindStringIndex(low)
+		if m == nil {
+			break
+		}
+		offsets = append(offsets, 0)
+		low = low[:m[0]] + " " + low[m[1]:]
+	}
+	low = strings.Join(strings.Fields(low), " ")
+
+	return NormalizeOffsets(offsets), low
+}
+
+// NormalizeOffsets сортирует смещения по убыванию и убирает повторы.
+// Пустой список превращается в смещение по умолчанию.
+func NormalizeOffsets(offsets []int) []int {
+	if len(offsets) == 0 {
+		return []int{defaultLeadMinutes}
+	}
+	sorted := append([]int(nil), offsets...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	out := sorted[:1]
+	for _, o := range sorted[1:] {
+		if o != out[len(out)-1] {
+			out = append(out, o)
 		}
-		low = strings.Replace(low, m[0], "", 1)
-		low = strings.TrimSpace(low)
 	}
+	return out
+}
+
+func leadUnitMinutes(unit string) int {
+	switch {
+	case unit == "", strings.HasPrefix(unit, "м"):
+		return 1
+	case unit == "полчаса":
+		return 30
+	case strings.HasPrefix(unit, "ч"):
+		return 60
+	case strings.HasPrefix(unit, "д"), strings.HasPrefix(unit, "сут"):
+		return 24 * 60
+	case strings.HasPrefix(unit, "нед"):
+		return 7 * 24 * 60
+	}
+	return 1
+}
+
+// parseNag вырезает «настойчиво [каждые N минут]».
+func parseNag(low string) (bool, int, string) {
+	m := reNag.FindStringSubmatchIndex(low)
+	if m == nil {
+		return false, 0, low
+	}
+	every := 0
+	if m[2] >= 0 {
+		every = toInt(low[m[2]:m[3]]) * leadUnitMinutes(low[m[4]:m[5]])
+	}
+	low = strings.Join(strings.Fields(low[:m[0]]+" "+low[m[1]:]), " ")
+	return true, every, low
+}
 
-	reRel := regexp.MustCompile(`\b(сегодня|завтра|послезавтра)\b(?:[^0-9]{0,10}(\d{1,2})[:.](\d{2}))?`)
+func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
+	low := strings.ToLower(strings.TrimSpace(input))
+	persistent, nagEvery, low := parseNag(low)
+	p, err := parseWhen(low, tz, now)
+	if err != nil {
+		return nil, err
+	}
+	p.Persistent, p.NagEvery = persistent, nagEvery
+	return p, nil
+}
+
+func parseWhen(low, tz string, now time.Time) (*Parsed, error) {
+	loc := storage.LoadUserLocation(tz)
+	lead, low := parseLeads(low)
+
+	reRel := regexp.MustCompile(wordStart + `(сегодня|завтра|послезавтра)` + wordEnd + `(?:[^0-9]{0,10}(\d{1,2})[:.](\d{2}))?`)
 	if m := reRel.FindStringSubmatch(low); len(m) >= 2 {
 		base := now.In(loc)
 		switch m[1] {
@@ -65,7 +162,7 @@ func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
 		if title == "" {
 			title = "дело"
 		}
-		return &Parsed{Title: title, DueUTC: &utc, LeadMinutes: lead}, nil
+		return &Parsed{Title: title, DueUTC: &utc, LeadOffsets: lead}, nil
 	}
 
 	reDate := regexp.MustCompile(`\b(\d{1,2})\s+([а-яё]+)\s*(?:,)?\s*(?:в\s*)?(\d{1,2})[:.](\d{2})\b`)
@@ -82,7 +179,7 @@ func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
 			if title == "" {
 				title = "дело"
 			}
-			return &Parsed{Title: title, DueUTC: &utc, LeadMinutes: lead}, nil
+			return &Parsed{Title: title, DueUTC: &utc, LeadOffsets: lead}, nil
 		}
 	}
 
@@ -99,10 +196,10 @@ func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
 		if title == "" {
 			title = "дело"
 		}
-		return &Parsed{Title: title, DueUTC: &utc, LeadMinutes: lead}, nil
+		return &Parsed{Title: title, DueUTC: &utc, LeadOffsets: lead}, nil
 	}
 
-	reWD := regexp.MustCompile(`\b(по|каждый|каждое)?\s*(?:в|во)?\s*(понедельник|вторник|среда|среду|четверг|пятница|пятницу|суббота|субботу|воскресенье)\b(?:[^0-9]{0,10}(\d{1,2})[:.](\d{2}))?`)
+	reWD := regexp.MustCompile(wordStart + `(по|каждый|каждую|каждое)?\s*(?:в|во)?\s*(понедельник|вторник|среда|среду|четверг|пятница|пятницу|суббота|субботу|воскресенье)` + wordEnd + `(?:[^0-9]{0,10}(\d{1,2})[:.](\d{2}))?`)
 	if m := reWD.FindStringSubmatch(low); len(m) >= 4 {
 		wd := map[string]time.Weekday{
 			"понедельник": time.Monday,
@@ -144,7 +241,7 @@ func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
 				time.Sunday:    "SU",
 			}[wd]
 			r := fmt.Sprintf("FREQ=WEEKLY;BYDAY=%s;BYHOUR=%d;BYMINUTE=%d", byday, hh, mm)
-			return &Parsed{Title: title, RRULE: &r, LeadMinutes: lead}, nil
+			return &Parsed{Title: title, RRULE: &r, LeadOffsets: lead}, nil
 		}
 
 		cur := now.In(loc)
@@ -156,7 +253,7 @@ func ParseRU(input, tz string, now time.Time) (*Parsed, error) {
 			cand = cand.Add(7 * 24 * time.Hour)
 		}
 		utc := cand.UTC()
-		return &Parsed{Title: title, DueUTC: &utc, LeadMinutes: lead}, nil
+		return &Parsed{Title: title, DueUTC: &utc, LeadOffsets: lead}, nil
 	}
 
 	return nil, fmt.Errorf("не распознал дату/время")
@@ -180,54 +277,6 @@ func toInt(s string) int {
 	return n
 }
 
-var ruWeek = map[string]int{
-	"пн": 1, "пон": 1, "понедельник": 1,
-	"вт": 2, "втор": 2, "вторник": 2,
-	"ср": 3, "среда": 3, "ср.": 3,
-	"чт": 4, "чет": 4, "четверг": 4,
-	"пт": 5, "пят": 5, "пятница": 5,
-	"сб": 6, "суб": 6, "суббота": 6,
-	"вс": 7, "воск": 7, "воскресенье": 7,
-}
-
-func ParseWeeklyEntries(raw string) ([]storage.WeeklyEntry, error) {
-	parts := strings.Split(raw, ";")
-	var out []storage.WeeklyEntry
-	for _, seg := range parts {
-		seg = strings.TrimSpace(seg)
-		if seg == "" {
-			continue
-		}
-
-		fields := strings.Fields(seg)
-		if len(fields) < 3 {
-			return nil, fmt.Errorf("bad segment: %q", seg)
-		}
-
-		wdRaw := strings.ToLower(strings.Trim(fields[0], ".,"))
-		wd, ok := ruWeek[wdRaw]
-		if !ok {
-			return nil, fmt.Errorf("bad weekday: %q", fields[0])
-		}
-
-		timeRaw := fields[1]
-		title := strings.TrimSpace(strings.Join(fields[2:], " "))
-
-		st, et, err := ParseTimeRange(timeRaw)
-		if err != nil {
-			return nil, err
-		}
-
-		out = append(out, storage.WeeklyEntry{
-			Weekday:   wd,
-			StartTime: st,
-			EndTime:   et,
-			Title:     title,
-		})
-	}
-	return out, nil
-}
-
 func ParseTimeRange(s string) (time.Time, *time.Time, error) {
 	s = strings.TrimSpace(s)
 	s = strings.ReplaceAll(s, "–", "-")
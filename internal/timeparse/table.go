@@ -0,0 +1,186 @@
+package timeparse
+
+import (
+	"TelegramBot/internal/storage"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tableColumns — названия колонок в заголовке таблицы расписания.
+var tableColumns = map[string]string{
+	"день": "weekday", "дни": "weekday", "день недели": "weekday", "weekday": "weekday", "day": "weekday", "days": "weekday",
+	"начало": "start", "время": "start", "start": "start", "time": "start", "from": "start",
+	"конец": "end", "окончание": "end", "end": "end", "to": "end", "until": "end",
+	"название": "title", "предмет": "title", "занятие": "title", "title": "title", "subject": "title", "name": "title",
+	"место": "location", "аудитория": "location", "location": "location", "room": "location", "place": "location",
+	"чётность": "parity", "четность": "parity", "неделя": "parity", "parity": "parity", "week": "parity",
+}
+
+// defaultColumns — порядок колонок, если заголовка нет.
+var defaultColumns = []string{"weekday", "start", "end", "title", "location", "parity"}
+
+// maxTableErrors — сколько ошибок показывать, остальные только считаются.
+const maxTableErrors = 10
+
+// TableError — ошибки разбора таблицы, по одной на строку.
+type TableError struct {
+	Errors []string
+	Total  int
+}
+
+func (e *TableError) Error() string {
+	s := strings.Join(e.Errors, "\n")
+	if e.Total > len(e.Errors) {
+		s += fmt.Sprintf("\n… и ещё ошибок: %d", e.Total-len(e.Errors))
+	}
+	return s
+}
+
+// ParseParity разбирает чётность недели: пусто, «каждая» — каждую неделю,
+// «числ»/«знам», «нечётная»/«чётная», odd/even, 1/2.
+func ParseParity(s string) (int, bool) {
+	s = strings.ToLower(strings.Trim(strings.TrimSpace(s), "."))
+	switch s {
+	case "", "0", "все", "каждая", "every", "any":
+		return storage.ParityAny, true
+	case "1", "нечёт", "нечет":
+		return storage.ParityOdd, true
+	case "2", "чёт", "чет":
+		return storage.ParityEven, true
+	}
+	v, ok := parityWords[s]
+	return v, ok
+}
+
+// ParseTimetableTable разбирает расписание в виде CSV, TSV или таблицы,
+// вставленной из электронной таблицы: день, начало, конец, название, место,
+// чётность. Разделитель (табуляция, «;» или «,») определяется по первой
+// строке; заголовок с названиями колонок необязателен и задаёт их порядок.
+// День может быть диапазоном или списком, как в /timetable set. Все строки
+// проверяются, и при ошибках возвращается *TableError со списком.
+func ParseTimetableTable(data []byte) ([]storage.WeeklyEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = detectDelimiter(data)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var out []storage.WeeklyEntry
+	var errs TableError
+	fail := func(line int, format string, args ...any) {
+		errs.Total++
+		if len(errs.Errors) < maxTableErrors {
+			errs.Errors = append(errs.Errors, fmt.Sprintf("строка %d: ", line)+fmt.Sprintf(format, args...))
+		}
+	}
+
+	columns := defaultColumns
+	first := true
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать таблицу: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		if blankRecord(rec) {
+			continue
+		}
+		if first {
+			first = false
+			if cols, ok := headerColumns(rec); ok {
+				columns = cols
+				continue
+			}
+		}
+
+		cell := map[string]string{}
+		for i, v := range rec {
+			if i < len(columns) && columns[i] != "" {
+				cell[columns[i]] = strings.TrimSpace(v)
+			}
+		}
+
+		days, err := ParseDays(cell["weekday"])
+		if err != nil {
+			var pe *ParseError
+			if errors.As(err, &pe) {
+				err = errors.New(pe.Msg)
+			}
+			fail(line, "день: %v", err)
+			continue
+		}
+		// в колонке начала может быть сразу интервал «9:00-10:30»
+		span := cell["start"]
+		if cell["end"] != "" && !strings.ContainsAny(span, "-–") {
+			span += "-" + cell["end"]
+		}
+		st, et, err := ParseTimeRange(span)
+		if err != nil {
+			fail(line, "не понял время %q", span)
+			continue
+		}
+		parity, ok := ParseParity(cell["parity"])
+		if !ok {
+			fail(line, "не понял чётность %q", cell["parity"])
+			continue
+		}
+		if cell["title"] == "" {
+			fail(line, "нет названия")
+			continue
+		}
+		for _, d := range days {
+			out = append(out, storage.WeeklyEntry{
+				Weekday:   d,
+				StartTime: st,
+				EndTime:   et,
+				Title:     cell["title"],
+				Location:  cell["location"],
+				Parity:    parity,
+			})
+		}
+	}
+	if errs.Total > 0 {
+		return nil, &errs
+	}
+	return out, nil
+}
+
+func detectDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	switch {
+	case bytes.ContainsRune(line, '\t'):
+		return '\t'
+	case bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")):
+		return ';'
+	default:
+		return ','
+	}
+}
+
+func blankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// headerColumns узнаёт заголовок: первая ячейка — известное название колонки.
+func headerColumns(rec []string) ([]string, bool) {
+	if _, ok := tableColumns[strings.ToLower(strings.TrimSpace(rec[0]))]; !ok {
+		return nil, false
+	}
+	cols := make([]string, len(rec))
+	for i, v := range rec {
+		cols[i] = tableColumns[strings.ToLower(strings.TrimSpace(v))]
+	}
+	return cols, true
+}
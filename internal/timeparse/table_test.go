@@ -0,0 +1,134 @@
+package timeparse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTimetableTable(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "tsv without header",
+			in:   "Пн\t9:00\t10:30\tМатан\tауд. 301\tчисл\nВт-Чт\t12\t13\tОбед\n",
+			want: []string{
+				"Mo 09:00-10:30 Матан odd @ ауд. 301",
+				"Tu 12:00-13:00 Обед", "We 12:00-13:00 Обед", "Th 12:00-13:00 Обед",
+			},
+		},
+		{
+			name: "numeric parity and empty location",
+			in:   "Пт\t8\t9\tЛаба\t\t2",
+			want: []string{"Fr 08:00-09:00 Лаба even"},
+		},
+		{
+			name: "semicolon header in own order with bom and crlf",
+			in:   "\ufeffНазвание;День;Время;Аудитория\r\nФизика;Пн,Ср;10-11:30;204\r\n",
+			want: []string{"Mo 10:00-11:30 Физика @ 204", "We 10:00-11:30 Физика @ 204"},
+		},
+		{
+			name: "semicolon detected despite comma in title",
+			in:   "Пн;9;10;Лекция, поток 1",
+			want: []string{"Mo 09:00-10:00 Лекция, поток 1"},
+		},
+		{
+			name: "comma with quoted day list and blank lines",
+			in:   "день,начало,конец,название\n\n\"Пн,Ср\",18:00,19:00,Gym\n,,,\n",
+			want: []string{"Mo 18:00-19:00 Gym", "We 18:00-19:00 Gym"},
+		},
+		{
+			name: "unknown header column is ignored",
+			in:   "day,start,comment,title\nMon,9,whatever,Run",
+			want: []string{"Mo 09:00 Run"},
+		},
+		{
+			name: "range in start cell wins over end column",
+			in:   "Сб\t10-12\t13\tБассейн",
+			want: []string{"Sa 10:00-12:00 Бассейн"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseTimetableTable([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("ParseTimetableTable(%q): %v", tt.in, err)
+			}
+			got := make([]string, 0, len(entries))
+			for _, e := range entries {
+				got = append(got, entryLine(e))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTimetableTable(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimetableTableErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		want  []string
+		total int
+	}{
+		{
+			name: "every bad row is reported with its line",
+			in:   "Пн\t9\t10\tА\nХз\t9\t10\tБ\nВт\t25\t\tВ\nСр\t9\t10\t\nЧт\t9\t10\tГ\tx\tчерез\n",
+			want: []string{
+				`строка 2: день: не понял день недели "Хз"`,
+				`строка 3: не понял время "25"`,
+				`строка 4: нет названия`,
+				`строка 5: не понял чётность "через"`,
+			},
+			total: 4,
+		},
+		{
+			name:  "line numbers count header and blank lines",
+			in:    "день;время;название\n\nПн;9;\n",
+			want:  []string{"строка 3: нет названия"},
+			total: 1,
+		},
+		{
+			name:  "list is capped",
+			in:    strings.Repeat("Пн;9;10;\n", maxTableErrors+2),
+			total: maxTableErrors + 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTimetableTable([]byte(tt.in))
+			var te *TableError
+			if !errors.As(err, &te) {
+				t.Fatalf("ParseTimetableTable(%q) error = %v, want *TableError", tt.in, err)
+			}
+			if te.Total != tt.total {
+				t.Errorf("Total = %d, want %d", te.Total, tt.total)
+			}
+			if tt.want != nil && !reflect.DeepEqual(te.Errors, tt.want) {
+				t.Errorf("Errors\n got %q\nwant %q", te.Errors, tt.want)
+			}
+			if len(te.Errors) > maxTableErrors {
+				t.Errorf("len(Errors) = %d, want at most %d", len(te.Errors), maxTableErrors)
+			}
+			if te.Total > len(te.Errors) && !strings.Contains(te.Error(), "и ещё ошибок: 2") {
+				t.Errorf("Error() = %q, want the rest counted", te.Error())
+			}
+		})
+	}
+}
+
+func TestParseParity(t *testing.T) {
+	for in, want := range map[string]int{"": 0, "каждая": 0, "1": 1, "Числ.": 1, "нечёт": 1, "even": 2, "чет": 2} {
+		if got, ok := ParseParity(in); !ok || got != want {
+			t.Errorf("ParseParity(%q) = %d, %v; want %d", in, got, ok, want)
+		}
+	}
+	if _, ok := ParseParity("иногда"); ok {
+		t.Error(`ParseParity("иногда") accepted`)
+	}
+}
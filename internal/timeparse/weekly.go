@@ -0,0 +1,336 @@
+package timeparse
+
+import (
+	"TelegramBot/internal/storage"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var weekDays = map[string]int{
+	"пн": 1, "пон": 1, "понедельник": 1,
+	"вт": 2, "втор": 2, "вторник": 2,
+	"ср": 3, "среда": 3,
+	"чт": 4, "чет": 4, "четверг": 4,
+	"пт": 5, "пят": 5, "пятница": 5,
+	"сб": 6, "суб": 6, "суббота": 6,
+	"вс": 7, "воск": 7, "воскресенье": 7,
+
+	"mo": 1, "mon": 1, "monday": 1,
+	"tu": 2, "tue": 2, "tues": 2, "tuesday": 2,
+	"we": 3, "wed": 3, "wednesday": 3,
+	"th": 4, "thu": 4, "thur": 4, "thurs": 4, "thursday": 4,
+	"fr": 5, "fri": 5, "friday": 5,
+	"sa": 6, "sat": 6, "saturday": 6,
+	"su": 7, "sun": 7, "sunday": 7,
+}
+
+// dayGroups — слова, означающие сразу несколько дней.
+var dayGroups = map[string][]int{
+	"будни":    {1, 2, 3, 4, 5},
+	"выходные": {6, 7},
+	"weekdays": {1, 2, 3, 4, 5},
+	"weekends": {6, 7},
+	"weekend":  {6, 7},
+}
+
+// parityWords — пометка чередования недель после списка дней.
+var parityWords = map[string]int{
+	"числ": storage.ParityOdd, "числитель": storage.ParityOdd, "нечётная": storage.ParityOdd, "нечетная": storage.ParityOdd, "odd": storage.ParityOdd,
+	"знам": storage.ParityEven, "знаменатель": storage.ParityEven, "чётная": storage.ParityEven, "четная": storage.ParityEven, "even": storage.ParityEven,
+}
+
+// rePeriodTail — «с 01.09» / «по 28.12.2025» в конце названия.
+var rePeriodTail = regexp.MustCompile(`(?i)(?:^|\s)(с|по|до|from|to|until)\s+(\d{1,2})\.(\d{1,2})(?:\.(\d{2}|\d{4}))?\s*$`)
+
+// ParseError — ошибка разбора расписания с указанием места.
+type ParseError struct {
+	// Segment — номер сегмента между «;», с 1.
+	Segment int
+	// Pos — позиция символа во всей строке, с 1.
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("сегмент %d, позиция %d: %s", e.Segment, e.Pos, e.Msg)
+}
+
+// ParseWeekday разбирает название дня недели («Пн», «среда», «Fri»),
+// 1 — понедельник.
+func ParseWeekday(s string) (int, bool) {
+	wd, ok := weekDays[strings.ToLower(strings.Trim(s, ".,"))]
+	return wd, ok
+}
+
+// ParseWeeklyEntries разбирает расписание вида
+// "Пн-Пт 9-18 Работа; Вт,Чт 19:00 Спорт; выходные 10-12 Бассейн".
+// Дни задаются одним днём, диапазоном (Пн-Пт, в том числе через
+// воскресенье: Пт-Пн), списком через запятую или словами
+// «будни»/«выходные»; каждая запись разворачивается в WeeklyEntry на день.
+//
+// Полная форма сегмента:
+//
+//	<дни> [числ|знам] <время> <название> [с ДД.ММ[.ГГГГ]] [по ДД.ММ[.ГГГГ]] [@ место] [// заметка]
+//
+// Даты без года относятся к текущему году, а конец раньше начала — к
+// следующему (семестр сентябрь–январь).
+func ParseWeeklyEntries(raw string) ([]storage.WeeklyEntry, error) {
+	var out []storage.WeeklyEntry
+	p := weeklyParser{raw: raw}
+	start := 0
+	for start <= len(raw) {
+		end := strings.IndexByte(raw[start:], ';')
+		if end < 0 {
+			end = len(raw)
+		} else {
+			end += start
+		}
+		p.segment++
+		entries, err := p.parseSegment(start, end)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+		start = end + 1
+	}
+	return out, nil
+}
+
+type weeklyParser struct {
+	raw     string
+	segment int
+}
+
+func (p *weeklyParser) errorf(off int, format string, args ...any) error {
+	return &ParseError{
+		Segment: p.segment,
+		Pos:     utf8.RuneCountInString(p.raw[:off]) + 1,
+		Msg:     fmt.Sprintf(format, args...),
+	}
+}
+
+// skipSpace возвращает смещение первого непробельного символа в [i, end).
+func (p *weeklyParser) skipSpace(i, end int) int {
+	for i < end {
+		r, size := utf8.DecodeRuneInString(p.raw[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// word возвращает смещение конца слова, начинающегося в i: до пробела или
+// одного из символов stop.
+func (p *weeklyParser) word(i, end int, stop string) int {
+	for i < end {
+		r, size := utf8.DecodeRuneInString(p.raw[i:])
+		if unicode.IsSpace(r) || strings.ContainsRune(stop, r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func (p *weeklyParser) parseSegment(start, end int) ([]storage.WeeklyEntry, error) {
+	i := p.skipSpace(start, end)
+	if i == end {
+		return nil, nil
+	}
+
+	days, i, err := p.parseDayList(i, end)
+	if err != nil {
+		return nil, err
+	}
+
+	parity := storage.ParityAny
+	if j := p.word(i, end, ""); j > i {
+		if v, ok := parityWords[strings.ToLower(strings.Trim(p.raw[i:j], ".,"))]; ok {
+			parity = v
+			i = p.skipSpace(j, end)
+		}
+	}
+
+	if i == end {
+		return nil, p.errorf(i, "ожидалось время, например 9-18 или 19:00")
+	}
+	j := p.word(i, end, "")
+	st, et, err := ParseTimeRange(p.raw[i:j])
+	if err != nil {
+		return nil, p.errorf(i, "не понял время %q", p.raw[i:j])
+	}
+
+	i = p.skipSpace(j, end)
+	tmpl := storage.WeeklyEntry{StartTime: st, EndTime: et, Parity: parity}
+	if err := p.parseTail(i, end, &tmpl); err != nil {
+		return nil, err
+	}
+
+	out := make([]storage.WeeklyEntry, 0, len(days))
+	for _, d := range days {
+		e := tmpl
+		e.Weekday = d
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// parseTail разбирает название вместе с необязательными периодом, местом и
+// заметкой.
+func (p *weeklyParser) parseTail(start, end int, e *storage.WeeklyEntry) error {
+	if k := strings.Index(p.raw[start:end], "//"); k >= 0 {
+		e.Notes = strings.TrimSpace(p.raw[start+k+2 : end])
+		end = start + k
+	}
+	if k := strings.IndexByte(p.raw[start:end], '@'); k >= 0 {
+		e.Location = strings.TrimSpace(p.raw[start+k+1 : end])
+		end = start + k
+	}
+
+	var fromYear, toYear bool
+	for n := 0; n < 2; n++ {
+		m := rePeriodTail.FindStringSubmatchIndex(p.raw[start:end])
+		if m == nil {
+			break
+		}
+		word := strings.ToLower(p.raw[start+m[2] : start+m[3]])
+		d, explicit, err := p.parseDate(start, m)
+		if err != nil {
+			return err
+		}
+		switch word {
+		case "с", "from":
+			if e.ValidFrom != nil {
+				return p.errorf(start+m[2], "начало периода указано дважды")
+			}
+			e.ValidFrom, fromYear = &d, explicit
+		default:
+			if e.ValidTo != nil {
+				return p.errorf(start+m[2], "конец периода указан дважды")
+			}
+			e.ValidTo, toYear = &d, explicit
+		}
+		end = start + m[0]
+	}
+	if e.ValidFrom != nil && e.ValidTo != nil && e.ValidTo.Before(*e.ValidFrom) {
+		if fromYear || toYear {
+			return p.errorf(start, "конец периода раньше начала")
+		}
+		t := e.ValidTo.AddDate(1, 0, 0)
+		e.ValidTo = &t
+	}
+
+	e.Title = strings.TrimSpace(p.raw[start:end])
+	if e.Title == "" {
+		return p.errorf(start, "ожидалось название")
+	}
+	return nil
+}
+
+// parseDate собирает дату из совпадения rePeriodTail; explicit — год указан.
+func (p *weeklyParser) parseDate(base int, m []int) (time.Time, bool, error) {
+	num := func(k int) int {
+		v, _ := strconv.Atoi(p.raw[base+m[2*k] : base+m[2*k+1]])
+		return v
+	}
+	day, month := num(2), num(3)
+	year, explicit := time.Now().Year(), m[8] >= 0
+	if explicit {
+		year = num(4)
+		if year < 100 {
+			year += 2000
+		}
+	}
+	d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if d.Day() != day || int(d.Month()) != month {
+		return time.Time{}, false, p.errorf(base+m[4], "нет такой даты %q", p.raw[base+m[4]:base+m[1]])
+	}
+	return d, explicit, nil
+}
+
+// ParseDays разбирает список дней целиком: «Пн», «Пн-Пт», «Вт, Чт»,
+// «будни».
+func ParseDays(s string) ([]int, error) {
+	p := weeklyParser{raw: s, segment: 1}
+	i := p.skipSpace(0, len(s))
+	days, i, err := p.parseDayList(i, len(s))
+	if err != nil {
+		return nil, err
+	}
+	if i < len(s) {
+		return nil, p.errorf(i, "лишнее после дней недели: %q", s[i:])
+	}
+	return days, nil
+}
+
+// parseDayList разбирает элементы дней через запятую, пробелы вокруг
+// запятой допустимы. Возвращает дни без повторов и смещение после списка.
+func (p *weeklyParser) parseDayList(i, end int) ([]int, int, error) {
+	var days []int
+	var seen [8]bool
+	for {
+		j := p.word(i, end, ",")
+		if j == i {
+			return nil, i, p.errorf(i, "ожидался день недели")
+		}
+		item, err := p.parseDays(i, j)
+		if err != nil {
+			return nil, i, err
+		}
+		for _, d := range item {
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+		}
+		i = p.skipSpace(j, end)
+		if i < end && p.raw[i] == ',' {
+			i = p.skipSpace(i+1, end)
+			continue
+		}
+		return days, i, nil
+	}
+}
+
+// parseDays разбирает один элемент списка дней: день, диапазон или группу.
+func (p *weeklyParser) parseDays(start, end int) ([]int, error) {
+	item := strings.ToLower(strings.TrimRight(p.raw[start:end], "."))
+	if g, ok := dayGroups[item]; ok {
+		return g, nil
+	}
+
+	sep := strings.IndexAny(item, "-–")
+	if sep < 0 {
+		wd, ok := weekDays[item]
+		if !ok {
+			return nil, p.errorf(start, "не понял день недели %q", p.raw[start:end])
+		}
+		return []int{wd}, nil
+	}
+
+	_, sepSize := utf8.DecodeRuneInString(item[sep:])
+	from, ok := weekDays[strings.TrimRight(item[:sep], ".")]
+	if !ok {
+		return nil, p.errorf(start, "не понял день недели %q", p.raw[start:start+sep])
+	}
+	toStart := start + sep + sepSize
+	to, ok := weekDays[strings.TrimRight(item[sep+sepSize:], ".")]
+	if !ok {
+		return nil, p.errorf(toStart, "не понял день недели %q", p.raw[toStart:end])
+	}
+	var out []int
+	for d := from; ; d = d%7 + 1 {
+		out = append(out, d)
+		if d == to {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,185 @@
+package timeparse
+
+import (
+	"TelegramBot/internal/storage"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entryLine — запись расписания одной строкой для сравнения в тестах:
+// "день старт-конец название [чётность] [с дата] [по дата] [@ место] [// заметка]".
+func entryLine(e storage.WeeklyEntry) string {
+	var b strings.Builder
+	b.WriteString(weekdayCode(e.Weekday) + " " + e.StartTime.Format("15:04"))
+	if e.EndTime != nil {
+		b.WriteString("-" + e.EndTime.Format("15:04"))
+	}
+	b.WriteString(" " + e.Title)
+	switch e.Parity {
+	case storage.ParityOdd:
+		b.WriteString(" odd")
+	case storage.ParityEven:
+		b.WriteString(" even")
+	}
+	if e.ValidFrom != nil {
+		b.WriteString(" с " + e.ValidFrom.Format(time.DateOnly))
+	}
+	if e.ValidTo != nil {
+		b.WriteString(" по " + e.ValidTo.Format(time.DateOnly))
+	}
+	if e.Location != "" {
+		b.WriteString(" @ " + e.Location)
+	}
+	if e.Notes != "" {
+		b.WriteString(" // " + e.Notes)
+	}
+	return b.String()
+}
+
+func weekdayCode(d int) string {
+	return []string{"?", "Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}[d]
+}
+
+func TestParseWeeklyEntries(t *testing.T) {
+	year := time.Now().Year()
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "ranges lists and groups",
+			in:   "Пн-Пт 9-18 Работа; Вт,Чт 19:00 Спорт; выходные 10-12 Бассейн",
+			want: []string{
+				"Mo 09:00-18:00 Работа", "Tu 09:00-18:00 Работа", "We 09:00-18:00 Работа",
+				"Th 09:00-18:00 Работа", "Fr 09:00-18:00 Работа",
+				"Tu 19:00 Спорт", "Th 19:00 Спорт",
+				"Sa 10:00-12:00 Бассейн", "Su 10:00-12:00 Бассейн",
+			},
+		},
+		{
+			name: "range through sunday",
+			in:   "Пт-Пн 8-9 Зарядка",
+			want: []string{"Fr 08:00-09:00 Зарядка", "Sa 08:00-09:00 Зарядка", "Su 08:00-09:00 Зарядка", "Mo 08:00-09:00 Зарядка"},
+		},
+		{
+			name: "spaces around comma and duplicate days",
+			in:   "Вт , Чт, вт 10:00 Лекция",
+			want: []string{"Tu 10:00 Лекция", "Th 10:00 Лекция"},
+		},
+		{
+			name: "english days and en dash",
+			in:   "Mon,Wed 18–19:30 Gym",
+			want: []string{"Mo 18:00-19:30 Gym", "We 18:00-19:30 Gym"},
+		},
+		{
+			name: "full form",
+			in:   "Пн числ 9-10:30 Матан с 01.09.2026 по 28.12.2026 @ ауд. 301 // конспект",
+			want: []string{"Mo 09:00-10:30 Матан odd с 2026-09-01 по 2026-12-28 @ ауд. 301 // конспект"},
+		},
+		{
+			name: "even parity word",
+			in:   "Ср знаменатель 12 Физика",
+			want: []string{"We 12:00 Физика even"},
+		},
+		{
+			name: "period without year rolls over",
+			in:   "Чт 9 Семинар с 01.09 по 20.01",
+			want: []string{"Th 09:00 Семинар с " + time.Date(year, 9, 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly) +
+				" по " + time.Date(year+1, 1, 20, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)},
+		},
+		{
+			name: "title ending with preposition is not a period",
+			in:   "Пт 18 Встреча с друзьями",
+			want: []string{"Fr 18:00 Встреча с друзьями"},
+		},
+		{
+			name: "empty segments",
+			in:   " ; Сб 11 Йога;",
+			want: []string{"Sa 11:00 Йога"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseWeeklyEntries(tt.in)
+			if err != nil {
+				t.Fatalf("ParseWeeklyEntries(%q): %v", tt.in, err)
+			}
+			got := make([]string, 0, len(entries))
+			for _, e := range entries {
+				got = append(got, entryLine(e))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWeeklyEntries(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWeeklyEntriesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		segment int
+		pos     int
+		msg     string
+	}{
+		{"unknown day in second segment", "Пн 9-10 Матан; Xx 10 Йога", 2, 16, `не понял день недели "Xx"`},
+		{"unknown range end", "Пн-Хз 9 Работа", 1, 4, `не понял день недели "Хз"`},
+		{"leading comma", ", Пн 9 Работа", 1, 1, "ожидался день недели"},
+		{"missing time", "Пн", 1, 3, "ожидалось время"},
+		{"bad hour", "Пн 25:00 Работа", 1, 4, `не понял время "25:00"`},
+		{"missing title", "Пн 9-10", 1, 8, "ожидалось название"},
+		{"missing title after period", "Пн 9-10 с 01.09", 1, 9, "ожидалось название"},
+		{"no such date", "Вт 9-10 Матан с 31.02.2026", 1, 17, `нет такой даты "31.02.2026"`},
+		{"period end before start", "Пн 9-10 Матан с 01.09.2026 по 01.08.2026", 1, 9, "конец периода раньше начала"},
+		{"period start twice", "Пн 9-10 Матан с 01.09.2026 с 02.09.2026", 1, 15, "начало периода указано дважды"},
+		{"position counts runes", "Пн 9 А; Вт 10 Б; Ср x В", 3, 21, `не понял время "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWeeklyEntries(tt.in)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ParseWeeklyEntries(%q) error = %v, want *ParseError", tt.in, err)
+			}
+			if pe.Segment != tt.segment || pe.Pos != tt.pos {
+				t.Errorf("ParseWeeklyEntries(%q) at segment %d pos %d, want segment %d pos %d (%s)",
+					tt.in, pe.Segment, pe.Pos, tt.segment, tt.pos, pe.Msg)
+			}
+			if !strings.Contains(pe.Msg, tt.msg) {
+				t.Errorf("ParseWeeklyEntries(%q) msg = %q, want it to contain %q", tt.in, pe.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+		pos  int
+	}{
+		{in: "будни", want: []int{1, 2, 3, 4, 5}},
+		{in: "Сб-Вс", want: []int{6, 7}},
+		{in: " пн., ср ", want: []int{1, 3}},
+		{in: "Пн-Пт x", pos: 7},
+		{in: "Пн,", pos: 4},
+	}
+	for _, tt := range tests {
+		days, err := ParseDays(tt.in)
+		if tt.pos > 0 {
+			var pe *ParseError
+			if !errors.As(err, &pe) || pe.Pos != tt.pos {
+				t.Errorf("ParseDays(%q) error = %v, want position %d", tt.in, err, tt.pos)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(days, tt.want) {
+			t.Errorf("ParseDays(%q) = %v, %v; want %v", tt.in, days, err, tt.want)
+		}
+	}
+}
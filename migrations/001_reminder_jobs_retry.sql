@@ -0,0 +1,10 @@
+-- Повторные попытки отправки и dead letter для reminder_jobs.
+ALTER TABLE reminder_jobs
+    ADD COLUMN IF NOT EXISTS attempts        integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error      text,
+    ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz,
+    ADD COLUMN IF NOT EXISTS failed_at       timestamptz;
+
+CREATE INDEX IF NOT EXISTS reminder_jobs_due_idx
+    ON reminder_jobs (COALESCE(next_attempt_at, report_time))
+    WHERE sent_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,8 @@
+-- Job'ы, пропущенные политикой догоняющей отправки после простоя.
+ALTER TABLE reminder_jobs
+    ADD COLUMN IF NOT EXISTS skipped_at timestamptz;
+
+DROP INDEX IF EXISTS reminder_jobs_due_idx;
+CREATE INDEX IF NOT EXISTS reminder_jobs_due_idx
+    ON reminder_jobs (COALESCE(next_attempt_at, report_time))
+    WHERE sent_at IS NULL AND failed_at IS NULL AND skipped_at IS NULL;
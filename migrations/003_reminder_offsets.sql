@@ -0,0 +1,6 @@
+-- Несколько напоминаний на одно событие («за день и за час»).
+ALTER TABLE reminders
+    ADD COLUMN IF NOT EXISTS reminder_offsets integer[];
+
+UPDATE reminders SET reminder_offsets = ARRAY[reminder_time]
+WHERE reminder_offsets IS NULL;
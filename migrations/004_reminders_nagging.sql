@@ -0,0 +1,9 @@
+-- Настойчивые напоминания: повтор каждые nag_interval минут до нажатия «Готово».
+ALTER TABLE reminders
+    ADD COLUMN IF NOT EXISTS persistent      boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS nag_interval    integer,
+    ADD COLUMN IF NOT EXISTS nag_max         integer,
+    ADD COLUMN IF NOT EXISTS acknowledged_at timestamptz;
+
+ALTER TABLE reminder_jobs
+    ADD COLUMN IF NOT EXISTS nag_seq integer NOT NULL DEFAULT 0;
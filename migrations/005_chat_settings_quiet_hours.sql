@@ -0,0 +1,6 @@
+-- Тихие часы чата: delay — переносить напоминания на конец окна,
+-- silent — доставлять без звука (disable_notification).
+ALTER TABLE chat_settings
+    ADD COLUMN IF NOT EXISTS quiet_from time,
+    ADD COLUMN IF NOT EXISTS quiet_to   time,
+    ADD COLUMN IF NOT EXISTS quiet_mode text NOT NULL DEFAULT 'delay';
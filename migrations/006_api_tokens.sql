@@ -0,0 +1,9 @@
+-- Токены REST API, выдаются командой /token. Храним только sha256 от токена.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    token_hash text PRIMARY KEY,
+    chat_id    bigint      NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_chat_idx ON api_tokens (chat_id);
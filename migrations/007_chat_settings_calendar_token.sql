@@ -0,0 +1,3 @@
+-- Секретный токен для ссылки на ICS-календарь чата (/calendar).
+ALTER TABLE chat_settings
+    ADD COLUMN IF NOT EXISTS calendar_token text UNIQUE;
@@ -0,0 +1,5 @@
+-- Групповые чаты: автор напоминания и рассылка «всем».
+ALTER TABLE reminders
+    ADD COLUMN IF NOT EXISTS user_id     bigint,
+    ADD COLUMN IF NOT EXISTS author_name text,
+    ADD COLUMN IF NOT EXISTS broadcast   boolean NOT NULL DEFAULT false;
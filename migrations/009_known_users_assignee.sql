@@ -0,0 +1,18 @@
+-- Участники, которых видел бот: Bot API не умеет искать пользователя по
+-- @username, поэтому запоминаем их сами. has_private_chat — пользователь
+-- писал боту в личку, и ему можно слать личные сообщения.
+CREATE TABLE IF NOT EXISTS known_users (
+    user_id          bigint PRIMARY KEY,
+    username         text,
+    first_name       text        NOT NULL DEFAULT '',
+    last_name        text        NOT NULL DEFAULT '',
+    has_private_chat boolean     NOT NULL DEFAULT false,
+    updated_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS known_users_username_idx ON known_users (lower(username));
+
+-- Исполнитель напоминания: «@ivan завтра 10:00 отправить отчёт».
+ALTER TABLE reminders
+    ADD COLUMN IF NOT EXISTS assignee_id   bigint,
+    ADD COLUMN IF NOT EXISTS assignee_name text;
@@ -0,0 +1,8 @@
+-- Расписание участников группы: у каждой записи свой владелец, и
+-- /timetable set заменяет только записи того, кто её вызвал.
+-- NULL — общее расписание чата (личные чаты и REST API).
+ALTER TABLE weekly_schedule
+    ADD COLUMN IF NOT EXISTS user_id    bigint,
+    ADD COLUMN IF NOT EXISTS owner_name text;
+
+CREATE INDEX IF NOT EXISTS weekly_schedule_chat_user_idx ON weekly_schedule (chat_id, user_id);
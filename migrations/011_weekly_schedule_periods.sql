@@ -0,0 +1,10 @@
+-- Чередование недель (числитель/знаменатель), период действия записи
+-- (семестр), место и заметка.
+-- week_parity: 0 — каждую неделю, 1 — нечётные (числитель), 2 — чётные
+-- (знаменатель). Неделя считается от valid_from, а без него — по ISO-номеру.
+ALTER TABLE weekly_schedule
+    ADD COLUMN IF NOT EXISTS week_parity smallint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS valid_from  date,
+    ADD COLUMN IF NOT EXISTS valid_to    date,
+    ADD COLUMN IF NOT EXISTS location    text,
+    ADD COLUMN IF NOT EXISTS notes       text;
@@ -0,0 +1,24 @@
+-- Разовые исключения из недельного расписания: отмена записи в конкретный
+-- день и разовое занятие. Перенос — пара «отмена + разовое».
+-- Отмена находит записи по владельцу и названию (и времени начала, если
+-- указано), поэтому переживает /timetable set, который пересоздаёт записи.
+CREATE TABLE IF NOT EXISTS schedule_exceptions (
+    id         bigserial PRIMARY KEY,
+    chat_id    bigint NOT NULL,
+    user_id    bigint,
+    owner_name text,
+    on_date    date NOT NULL,
+    kind       text NOT NULL CHECK (kind IN ('cancel', 'add')),
+    start_time time,
+    end_time   time,
+    title      text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS schedule_exceptions_chat_date_idx ON schedule_exceptions (chat_id, on_date);
+
+-- Производственный календарь РФ: off — не учитывать, timetable — в
+-- праздники не показывать расписание, all — ещё и пропускать
+-- повторяющиеся напоминания.
+ALTER TABLE chat_settings
+    ADD COLUMN IF NOT EXISTS holidays text NOT NULL DEFAULT 'off';
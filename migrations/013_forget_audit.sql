@@ -0,0 +1,9 @@
+-- Журнал удалений по /forget. Обезличен: ни chat_id, ни имён — только
+-- тип чата, сколько строк удалено из каких таблиц и когда. В таблице
+-- намеренно нет колонки chat_id, иначе /forget находил бы и чистил её.
+CREATE TABLE IF NOT EXISTS forget_audit (
+    id           bigserial PRIMARY KEY,
+    chat_kind    text        NOT NULL,
+    deleted_rows jsonb       NOT NULL,
+    forgotten_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,12 @@
+-- История напоминаний: отработавшие разовые напоминания не удаляются, а
+-- уходят в архив (archived_at). fired_at — когда напоминание последний раз
+-- отправлено, snoozed_count — сколько раз его откладывали. Архив старше
+-- HISTORY_RETENTION_DAYS удаляет фоновая очистка.
+ALTER TABLE reminders
+    ADD COLUMN IF NOT EXISTS fired_at      timestamptz,
+    ADD COLUMN IF NOT EXISTS archived_at   timestamptz,
+    ADD COLUMN IF NOT EXISTS snoozed_count integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS reminders_chat_archived_idx
+    ON reminders (chat_id, archived_at)
+    WHERE archived_at IS NOT NULL;
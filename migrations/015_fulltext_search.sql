@@ -0,0 +1,9 @@
+-- Полнотекстовый поиск /find: текст напоминаний (и активных, и архива) и
+-- названия занятий индексируются сразу в русской и английской конфигурации.
+-- Выражения должны совпадать с запросом в storage/search.go, иначе индекс
+-- не используется.
+CREATE INDEX IF NOT EXISTS reminders_message_fts_idx
+    ON reminders USING gin ((to_tsvector('russian', message) || to_tsvector('english', message)));
+
+CREATE INDEX IF NOT EXISTS weekly_schedule_title_fts_idx
+    ON weekly_schedule USING gin ((to_tsvector('russian', title) || to_tsvector('english', title)));
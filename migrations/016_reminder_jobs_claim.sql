@@ -0,0 +1,5 @@
+-- Захват job'а перед отправкой: claimed_at ставится до запроса к Telegram.
+-- Захваченный, но не отмеченный отправленным job повторно не отправляется —
+-- после рестарта уведомитель только дописывает ему sent_at.
+ALTER TABLE reminder_jobs
+    ADD COLUMN IF NOT EXISTS claimed_at timestamptz;
@@ -0,0 +1,3 @@
+-- Ежечасная чистка удаляет прошедшие исключения расписания всех чатов
+-- по on_date, индекс (chat_id, on_date) для этого не подходит.
+CREATE INDEX IF NOT EXISTS schedule_exceptions_date_idx ON schedule_exceptions (on_date);